@@ -0,0 +1,57 @@
+'''
+Disturbance Tracker - DSP Golden Fixtures
+
+Saves the spectrogram of each tagged (.dat) clip, exactly as training sees it,
+as little-endian float32 [N_MELS x frames] next to the clip (.mel). The Go
+spectrogram is compared against these by "go test ./model" and by
+"dtrack -a dsp-check".
+'''
+import argparse
+import logging
+import pathlib
+
+# DTrack
+import ai.options
+import ai.model
+
+
+def write_golden(dat_path, mel_path):
+    '''
+    Save the training spectrogram of a single .dat clip.
+    '''
+    audio = ai.model.open_audio_file(dat_path)
+    spectrogram = ai.model.audio_to_spectrogram(audio).numpy()
+    expected = (1, ai.model.N_MELS, ai.model.SPECTROGRAM_FRAMES)
+    if spectrogram.shape != expected:
+        logging.warning('%s has shape %s; Go expects %s', dat_path,
+                        spectrogram.shape, expected)
+    spectrogram.astype('<f4').tofile(mel_path)
+    logging.info('Saved %s', mel_path)
+
+
+def main():
+    '''
+    Main entry point for the golden fixture generator.
+    '''
+    parser = argparse.ArgumentParser(
+        usage='python3 -m ai.golden [-o <dir>] <clip.dat> [...]')
+    parser.add_argument(
+        '-o',
+        dest='output',
+        metavar='<dir>',
+        help='Directory for .mel files (default: next to each clip)')
+    parser.add_argument(
+        'clips',
+        nargs='+',
+        metavar='<clip.dat>',
+        help='Tagged audio clips')
+    opts = parser.parse_args()
+    ai.options.configure_logging('INFO')
+
+    for clip in map(pathlib.Path, opts.clips):
+        output = pathlib.Path(opts.output) if opts.output else clip.parent
+        write_golden(clip, output / clip.with_suffix('.mel').name)
+
+
+if __name__ == '__main__':
+    main()
@@ -1,7 +1,6 @@
 '''
 Disturbance Tracker - Inspection Utility (Multi-Class)
 '''
-import json
 import logging
 import pathlib
 import pprint
@@ -22,21 +21,27 @@ def check_input():
     Main entry point for the inspection script.
     '''
     options = ai.options.bootstrap()
+    ai.model.configure(options['audio_rate'])
     workspace = pathlib.Path(options['workspace'])
 
     # Load models and their labels
-    if not options['inspect_models']:
-        raise ValueError('No inspection models are configured.')
+    if not ai.options.trained_models(options):
+        raise ValueError('No trained inspection models are configured.')
 
     loaded_models = {}
-    for model_name in options['inspect_models']:
+    for model_name in ai.options.trained_models(options):
         # Load Labels
         labels_path = workspace / 'models' / f'{model_name}.labels'
         if not labels_path.exists():
             raise FileNotFoundError(f'Labels missing for {model_name}')
 
-        with open(labels_path, 'r', encoding='utf-8') as fh:
-            labels = json.load(fh)
+        metadata = ai.model.read_labels(labels_path)
+        labels = metadata['labels']
+        if metadata['dsp']['sample_rate'] != ai.model.SAMPLE_RATE:
+            raise ValueError(
+                f'{model_name} was trained at '
+                f'{metadata["dsp"]["sample_rate"]} Hz; '
+                f'recording at {ai.model.SAMPLE_RATE} Hz')
 
         # Load Model
         pth_path = workspace / 'models' / f'{model_name}.pth'
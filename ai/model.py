@@ -1,7 +1,10 @@
 '''
 Disturbance Tracker - Machine Learning Model Definition
 '''
+import datetime
+import json
 import logging
+import pathlib
 
 # 3rd-Party
 import numpy as np
@@ -13,7 +16,7 @@ import librosa
 import ai.options
 
 
-# NOTE: Copied from src/ffmpeg/ffmpeg.go
+# NOTE: Copied from src/ffmpeg/ffmpeg.go (see configure)
 BYTES_PER_SECOND = 96000
 SAMPLE_RATE = 48000
 
@@ -23,15 +26,45 @@ SAMPLE_SIZE = SAMPLE_RATE * SEGMENT_SIZE
 N_MELS = 128
 N_FFT = 2048
 HOP_LENGTH = 512
+SPECTROGRAM_FRAMES = 1 + SAMPLE_SIZE // HOP_LENGTH
 
 # Mel Spectrogram Filter Bank
-MEL_BASIS = librosa.filters.mel(
-        htk=True,  # Force HTK math (Matches Go "2595/700" logic)
-        sr=SAMPLE_RATE,
-        n_fft=N_FFT,
-        n_mels=N_MELS,
-        fmax=float(SAMPLE_RATE / 2),
-        fmin=0.0)
+MEL_BASIS = None
+
+# Spectrogram settings saved with each model (see src/model/metadata.go)
+DSP = {}
+
+
+def configure(sample_rate):
+    '''
+    Recalculate every sample rate dependent value (see "audio_rate").
+    '''
+    # pylint: disable=global-statement
+    global BYTES_PER_SECOND, SAMPLE_RATE, SAMPLE_SIZE, SPECTROGRAM_FRAMES
+    global MEL_BASIS, DSP
+    SAMPLE_RATE = sample_rate
+    BYTES_PER_SECOND = SAMPLE_RATE * 2
+    SAMPLE_SIZE = SAMPLE_RATE * SEGMENT_SIZE
+    SPECTROGRAM_FRAMES = 1 + SAMPLE_SIZE // HOP_LENGTH
+
+    MEL_BASIS = librosa.filters.mel(
+            htk=True,  # Force HTK math (Matches Go "2595/700" logic)
+            sr=SAMPLE_RATE,
+            n_fft=N_FFT,
+            n_mels=N_MELS,
+            fmax=float(SAMPLE_RATE / 2),
+            fmin=0.0)
+
+    DSP = {
+        'sample_rate': SAMPLE_RATE,
+        'segment_size': SEGMENT_SIZE,
+        'n_mels': N_MELS,
+        'n_fft': N_FFT,
+        'hop_length': HOP_LENGTH,
+        'frames': SPECTROGRAM_FRAMES}
+
+
+configure(SAMPLE_RATE)
 
 # Use CUDA device if available, or else CPU
 CUDA_CPU = torch.device('cuda' if torch.cuda.is_available() else 'cpu')
@@ -143,12 +176,17 @@ def load(path, num_classes):
 def convert(pth, onnx, num_classes):
     '''
     Convert pytorch .pth model to ONNX (open model) format.
+
+    The input shape matches the configured spectrogram (see configure), with
+    any batch size, so scanners that fall behind can infer several windows.
     '''
     logging.info('Converting %s to %s', pth, onnx)
     model = load(pth, num_classes)
+    sample = torch.randn(1, 1, ai.model.N_MELS, ai.model.SPECTROGRAM_FRAMES)
     torch.onnx.export(
-        model, torch.randn(1, 1, 128, 188).to(ai.model.CUDA_CPU), onnx,
+        model, sample.to(ai.model.CUDA_CPU), onnx,
         input_names=['input'], output_names=['output'], opset_version=20,
+        dynamic_axes={'input': {0: 'batch'}, 'output': {0: 'batch'}},
         dynamo=False, verbose=False)
 
 
@@ -202,3 +240,32 @@ def audio_to_spectrogram(audio_data):
 
     # Return single-dimension channel (N, 1, H, W)
     return torch.tensor(np.expand_dims(img, axis=0), dtype=torch.float32)
+
+
+def read_labels(labels_path):
+    '''
+    Load a model's metadata; older models only saved a list of labels.
+    '''
+    with open(labels_path, 'r', encoding='utf-8') as fh:
+        metadata = json.load(fh)
+    if isinstance(metadata, list):
+        # Always trained at the default sample rate
+        metadata = {'labels': metadata, 'version': 0,
+                    'dsp': {**DSP, 'sample_rate': 48000,
+                            'frames': 1 + 96000 // HOP_LENGTH}}
+    return metadata
+
+
+def write_labels(labels_path, classes):
+    '''
+    Save labels, spectrogram settings, and a new version of a model.
+    '''
+    version = 1
+    if pathlib.Path(labels_path).exists():
+        version = read_labels(labels_path).get('version', 0) + 1
+    with open(labels_path, 'w', encoding='utf-8') as fh:
+        json.dump({
+            'labels': classes,
+            'version': version,
+            'trained': datetime.date.today().isoformat(),
+            'dsp': DSP}, fh, indent=2)
@@ -9,6 +9,7 @@ import logging
 # NOTE: Copied from src/state/config.go
 DTRACK_DEFAULTS = {
     'workspace': '_workspace',
+    'audio_rate': 48000,
     'inspect_models': [],
     'train_epochs': 200,
     'train_batch_size': 16,
@@ -39,6 +40,19 @@ def bootstrap():
     return {**DTRACK_DEFAULTS, **config, **vars(opts)}
 
 
+def trained_models(options):
+    '''
+    Names of inspection models trained by ai.train (ONNX detectors).
+    '''
+    names = []
+    for model in options['inspect_models']:
+        if isinstance(model, str):
+            names.append(model)
+        elif model.get('type', 'onnx') == 'onnx':
+            names.append(model['name'])
+    return names
+
+
 def configure_logging(log_level):
     '''
     Configure the root logger with a specific format and level.
@@ -5,7 +5,6 @@ Uses directory structure: tags/<model_name>/<class>/<audio_segment>.dat
 '''
 import logging
 import pathlib
-import json
 import sys
 
 # 3rd-Party
@@ -59,10 +58,11 @@ def train_all():
     Train all configured models using tagged audio clips.
     '''
     options = ai.options.bootstrap()
+    ai.model.configure(options['audio_rate'])
     workspace = pathlib.Path(options['workspace'])
     models_dir = workspace / 'models'
 
-    for model_name in options['inspect_models']:
+    for model_name in ai.options.trained_models(options):
         logging.debug('Begin training: %s', model_name)
 
         # Train (and get class count)
@@ -115,10 +115,7 @@ def train_model(model_name, options):
     models_dir.mkdir(parents=True, exist_ok=True)
 
     # Labels Map: Gather files and Calculate Counts for Balancing
-    with open(
-            models_dir / f'{model_name}.labels', 'w',
-            encoding='utf-8') as fh:
-        json.dump(classes, fh)
+    ai.model.write_labels(models_dir / f'{model_name}.labels', classes)
 
     # Gather files and Calculate Counts for Balancing
     all_files = []
@@ -275,8 +272,8 @@ def build_report(model_name, options):
     data_dir = workspace / 'tags' / model_name
 
     # Load labels
-    with open(models_dir / f'{model_name}.labels', 'r') as f:
-        classes = json.load(f)
+    classes = ai.model.read_labels(
+            models_dir / f'{model_name}.labels')['labels']
     class_to_idx = {c: i for i, c in enumerate(classes)}
 
     # Load model
@@ -7,74 +7,167 @@ package daemon
 
 import (
 	// DTrack
+	"dtrack/decision"
+	"dtrack/events"
 	"dtrack/ffmpeg"
+	"dtrack/ledger"
 	"dtrack/log"
 	"dtrack/model"
+	"dtrack/retention"
 	"dtrack/state"
 
 	// Standard
+	"errors"
 	"io"
 	"os"
-	"os/signal"
+	"slices"
+	"sync"
 	"time"
-
-	// 3rd-Party
-	"gorgonia.org/tensor"
 )
 
 // Segment of WAV data
 type audio_segment struct {
-	count uint
-	data  []byte
+	count    uint
+	data     []byte
+	location segment_location
+}
+
+// Check window, prepared for inspection
+type prepared_window struct {
+	count    uint   // Segment id; restarts with each stream
+	sequence uint64 // Unique for the life of the daemon (see scanner_pool.send)
+	audio    *model.Window
+	location segment_location
 }
 
+// Recordings still being added to the ledger
+var ledger_pending sync.WaitGroup
+
 // Primary post-bootstrap entry point
 // Initialize audio segment scanners and begin recording process
-func Run() {
+// Retention only lists recordings it would remove when dry_run is set.
+func Run(dry_run bool) {
 	wav_stream, daemon_stream := io.Pipe()
-	stop_recording := false
+	timeline := new_timeline(daemon_stream)
+	stopping := make(chan struct{})
+	pool := new_scanner_pool()
+	cfg := state.Runtime
+	settings.Store(&cfg)
+	stats.started = time.Now()
 
-	// Handle interrupt signals
-	sig_chan := make(chan os.Signal, 1)
-	signal.Notify(sig_chan, os.Interrupt)
-	go func() {
-		// Wait for first Ctrl+C
-		<-sig_chan
-		log.Info("SIGTERM: Waiting for current recording to finish.")
-		stop_recording = true
-		// Wait for second Ctrl+C
-		<-sig_chan
-		log.Die("Second Ctrl+C received. Terminating immediately.")
-	}()
+	// Handle interrupt (and other) signals
+	go handle_signals(stopping, pool)
 
 	// Start scanners if any models are defined
 	if state.Runtime.Has_Models {
 		log.Debug("Initializing segment scanners")
-		go start_scanners(wav_stream)
+		pool.update(cfg.Scanned_Models(), cfg.Record_Inspect_Backlog)
+		go pool.watch_models()
+		go supervise_stream(wav_stream, timeline, func(stream *io.PipeReader) error {
+			return inspect_stream(stream, timeline, pool)
+		})
 	} else {
 		log.Warn("No inspection models configured; only able to record!")
-		go Pipe2DevNull(wav_stream)
+		go supervise_stream(wav_stream, timeline, func(stream *io.PipeReader) error {
+			Pipe2DevNull(stream)
+			return io.EOF
+		})
 	}
 
-	// Prepare full ffmpeg command
-	record_args := ffmpeg.Recorder_Arguments()
-	save_path := state.Runtime.Workspace + "/recordings/"
+	// Enforce recording retention in the background
+	if retention.Enabled() {
+		go retention.Janitor(dry_run)
+	}
 
 	// Start main recording loop that sends data to scanners (and mkv recordings)
-	for !stop_recording {
-		mkv := save_path + time.Now().Format(ffmpeg.SaveName)
+	// ffmpeg is only restarted if it exits unexpectedly.
+	save_path := ffmpeg.Recordings_Directory()
+	supervisor := new_capture_supervisor(state.Runtime.Record_Max_Failures)
+	for !closed(stopping) {
 		// Verify output directory exists
 		if err := os.MkdirAll(save_path, 0755); err != nil {
 			log.Die("Failed to make output directory: %s", save_path)
 			return
 		}
+		err := capture(save_path, timeline, stopping, supervisor)
+		if err == nil {
+			continue
+		}
+
+		// Restart with increasing delays, until too many failures in a row
+		log.Warn("Capture failed: %s", err)
+		stats.failures.Add(1)
+		delay, retry := supervisor.failed(time.Now(), err)
+		if !retry {
+			supervisor.finish(time.Now())
+			ledger_pending.Wait()
+			log.Die("Capture failed %d times in a row; giving up", supervisor.failures)
+		}
+		log.Info("Restarting capture in %s", delay)
+		select {
+		case <-stopping:
+		case <-time.After(delay):
+		}
+	}
+
+	// Finish saving the outage (if any), incidents, and recordings before exiting
+	supervisor.finish(time.Now())
+	pool.stop()
+	ledger_pending.Wait()
+}
+
+// Run a single ffmpeg capture until it exits (or stopping is closed)
+// Returns nil only if the capture was asked to stop.
+func capture(save_path string, timeline *stream_timeline, stopping <-chan struct{}, supervisor *capture_supervisor) error {
+	started := time.Now()
+	segments := new_segment_tracker(started)
+	log.Debug("New ffmpeg process, saving to: %s", save_path)
+	stats.captures.Add(1)
+	timeline.begin(started)
+
+	finished := make(chan error, 1)
+	go func() {
+		finished <- ffmpeg.Capture(ffmpeg.Recorder_Arguments(save_path), timeline, stopping)
+	}()
+
+	// Ledger each recording once ffmpeg moves on to the next
+	poll := time.NewTicker(SegmentPoll)
+	defer poll.Stop()
+	for {
+		select {
+		case <-poll.C:
+			segments.update(false)
+			if time.Since(started) >= CaptureHealthy {
+				supervisor.recovered(started)
+			}
+		case err := <-finished:
+			segments.update(true)
+			if closed(stopping) {
+				return nil
+			}
+			if err == nil {
+				err = errors.New("ffmpeg stopped unexpectedly")
+			}
+			return err
+		}
+	}
+}
 
-		log.Debug("New ffmpeg process, saving to: %s", mkv)
-		args := append(record_args, mkv)
-		ffmpeg.ReadStdin(args, daemon_stream, false)
+// Returns true once a channel has been closed
+func closed(channel <-chan struct{}) bool {
+	select {
+	case <-channel:
+		return true
+	default:
+		return false
+	}
+}
 
-		// Pause to prevent thrashing of physical devices
-		time.Sleep(50 * time.Millisecond)
+// Add a finished recording to the evidence ledger
+func ledger_recording(mkv string) {
+	defer ledger_pending.Done()
+	if err := ledger.Record_File(mkv); err != nil {
+		log.Warn("Failed to add %s to ledger: %s", mkv, err)
 	}
 }
 
@@ -83,127 +176,233 @@ func Pipe2DevNull(r io.Reader) {
 	io.Copy(io.Discard, r)
 }
 
-// Initialize all audio segment scanners and process wav_stream data
-func start_scanners(wav_stream *io.PipeReader) {
-	// Process manager for segment scanners
-	scanners := make(map[string]chan *tensor.Dense)
-	returned_segments := make(chan audio_segment)
-
-	// Start segment scanner thread for each trained model
-	for _, model_name := range state.Runtime.Record_Inspect_Models {
-		segment_channel := make(
-			chan *tensor.Dense,
-			state.Runtime.Record_Inspect_Backlog)
-		scanners[model_name] = segment_channel
-		go scan_segments(model_name, segment_channel)
-	}
-
+// Send windows from a stream to every scanner, until the stream stops
+func inspect_stream(stream *io.PipeReader, timeline *stream_timeline, pool *scanner_pool) error {
 	// Stream converter
-	go stream_to_segment(wav_stream, returned_segments)
+	returned_segments := make(chan audio_segment)
+	converted := make(chan error, 1)
+	go func() {
+		converted <- stream_to_segment(stream, timeline, returned_segments)
+	}()
 
 	// Simple 2-count buffer
 	var last_segment audio_segment
 
+	// Reused for every window; audible windows are copied for scanners
+	check_window := make([]byte, 0, model.Sample_Size())
+
 	// Handle new audio segments
-	for {
-		// Collect new segment
-		new_segment, ok := <-returned_segments
-		if !ok {
-			log.Die("Stream converter disappeared")
-			return
-		}
+	for new_segment := range returned_segments {
 		// Save first segment seen, but delay processing until next segment
 		if last_segment.data == nil {
 			last_segment = new_segment
 			continue
 		}
 
-		// Combine two segments into a single prepared check window
-		check_window := append(last_segment.data, new_segment.data...)
-		preparedAudio, err := model.Prepare(check_window)
+		// Combine two segments into a single check window
+		check_window = append(append(check_window[:0], last_segment.data...), new_segment.data...)
+		// Window is located by its first segment
+		window := prepared_window{
+			count:    last_segment.count,
+			location: last_segment.location,
+		}
 		// Rotate last_segment before additional checks
 		last_segment = new_segment
-		if err != nil {
-			log.Warn("ML Prepare failed: %v", err)
+		if pool.empty() {
 			continue
 		}
+		stats.windows.Add(1)
 
-		// Distribute audio sample to scanners
-		for name, scanner := range scanners {
-			select {
-			// Send segment to individual scanner
-			case scanner <- preparedAudio:
-			default:
-				log.Warn("Scanner Blocked: %s", name)
-			}
+		// Energy gate: silent windows skip DSP and inference (audio = nil)
+		// Audible windows are prepared by scanners, once for each DSP setting.
+		if rms, peak := model.Level(check_window); peak < current().Record_Inspect_Silence {
+			log.Trace("Silent window %d (RMS: %.1f dBFS, Peak: %.1f dBFS)", window.count, rms, peak)
+			stats.silent.Add(1)
+		} else {
+			window.audio = model.New_Window(slices.Clone(check_window))
 		}
+
+		// Distribute audio sample to scanners
+		pool.send(window)
 	}
+	return <-converted
 }
 
-// Convert an input wav_stream to 1-second audio clips
-func stream_to_segment(stream *io.PipeReader, segments chan<- audio_segment) {
+// Convert an input wav_stream to 1-second audio clips, until the stream stops
+func stream_to_segment(stream io.Reader, timeline *stream_timeline, segments chan<- audio_segment) error {
 	defer close(segments)
 	var segment_id uint = 0
+	var position int64 = 0
 
 	// Start main conversion loop
 	for {
 		// Allocate a buffer for the audio segment
-		segment_data := make([]byte, ffmpeg.BytesPerSecond)
+		segment_data := make([]byte, ffmpeg.Bytes_Per_Second())
 
 		// Block until segment_data is full
-		_, err := io.ReadFull(stream, segment_data)
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			// WAV stream ended; restart fresh loop
-			log.Die("Stream reader vanished")
-			continue
-		}
-		if err != nil {
-			log.Die("Unhandled stream read error: %s", err.Error())
+		if _, err := io.ReadFull(stream, segment_data); err != nil {
+			return err
 		}
 
 		// Add new segment to queue
 		log.Trace("New segment accumulated: %d", segment_id)
 		segments <- audio_segment{
-			count: segment_id,
-			data:  segment_data,
+			count:    segment_id,
+			data:     segment_data,
+			location: timeline.locate(position),
 		}
 		segment_id++
+		position += int64(len(segment_data))
 	}
 }
 
 // Primary loop that tests each audio segment against a trained model
-func scan_segments(name string, audio_stream chan *tensor.Dense) {
-	// Load the model (and implicit json labels)
-	ml := model.Load(state.Runtime.Workspace + "/models/" + name + ".onnx")
+// Each decision is also sent to votes, for ensembles using this model.
+func scan_segments(name string, scanner *scanner, votes *coordinator) {
+	// Create the detector (loading ONNX models and labels); may be swapped while running
+	if detector, err := new_detector(name); err != nil {
+		scanner.disable(name, err)
+	} else {
+		scanner.detector.CompareAndSwap(nil, &detector)
+	}
+	incidents := new_incident_builder(
+		time.Duration(current().Record_Incident_Gap) * time.Second)
+	smoother := decision.New(decision.Configured(current()), func(class string) float64 {
+		return current().Trust(name, class)
+	})
 
-	for {
-		// Wait for prepared audio data
-		audio, ok := <-audio_stream
-		if !ok {
-			log.Die("Scanner unexpectedly closed: %s", name)
+	// Wait for prepared audio data, until the scanner is stopped
+	for first := range scanner.windows {
+		// Scanners that fall behind infer every waiting window at once
+		batch := next_batch(first, scanner.windows)
+		if len(batch) > 1 {
+			log.Debug("SCANNER %s: Behind; inferring %d windows at once", name, len(batch))
+			stats.batched.Add(uint64(len(batch)))
 		}
+		predictions, err := scanner.infer(batch)
+		if err != nil {
+			// Windows already waiting for a disabled scanner are never decided
+			scanner.disable(name, err)
+			for _, window := range batch {
+				votes.skip(window.sequence, name)
+				scanner.drop(name, window)
+			}
+			continue
+		}
+		for i, result := range predictions {
+			decide(name, batch[i], result, smoother, incidents, votes)
+		}
+	}
 
-		// Inference on preparedData (Returns map[string]float64)
-		predictions := model.Infer(ml, audio)
-
-		// Find the best match
-		bestClass := ""
-		bestConf := 0.0
+	// Incidents still open (and windows dropped since the pool stopped it) end here
+	save_incidents(name, incidents.flush())
+	scanner.caught_up()
+}
 
-		for label, score := range predictions {
-			if score > bestConf {
-				bestConf = score
-				bestClass = label
+// Take a window, plus (if the scanner is behind) the windows waiting after it
+func next_batch(first prepared_window, windows <-chan prepared_window) []prepared_window {
+	batch := []prepared_window{first}
+	for len(batch) < model.BatchMax {
+		select {
+		case window, ok := <-windows:
+			if !ok {
+				return batch
 			}
+			batch = append(batch, window)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// Returned while a scanner is disabled (see scanner.disable)
+var errDisabled = errors.New("scanner disabled")
+
+// Infer a batch with the scanner's current detector
+func (s *scanner) infer(batch []prepared_window) ([]map[string]float64, error) {
+	current := s.detector.Load()
+	switch {
+	case s.disabled.Load():
+		return nil, errDisabled
+	case current == nil:
+		return nil, errors.New("no model loaded")
+	}
+	return infer_windows(*current, batch)
+}
+
+// Probabilities of each window; silent windows are "empty" without inference
+func infer_windows(detector model.Detector, batch []prepared_window) ([]map[string]float64, error) {
+	predictions := make([]map[string]float64, len(batch))
+	audible := []int{}
+	windows := []*model.Window{}
+	for i, window := range batch {
+		predictions[i] = map[string]float64{decision.Empty: 1}
+		if window.audio != nil {
+			audible = append(audible, i)
+			windows = append(windows, window.audio)
 		}
+	}
+	if len(windows) == 0 {
+		return predictions, nil
+	}
 
-		// Decision Logic
-		// 1. Ignore "empty" class
-		// 2. Check if confidence is above Trust threshold
-		if bestClass != "empty" && bestConf > state.Runtime.Record_Inspect_Trust {
-			log.Info("SCANNER %s: MATCH found! Class: %s (Conf: %.4f)", name, bestClass, bestConf)
-		} else {
-			log.Trace("SCANNER %s: No match. Top: %s (Conf: %.4f)", name, bestClass, bestConf)
+	// Detect audible windows at once (Returns map[string]float64 per window)
+	results, err := detector.Detect(windows)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		predictions[audible[i]] = result
+	}
+	return predictions, nil
+}
+
+// Decide whether a single window is a match, then record and report it
+func decide(name string, window prepared_window, predictions map[string]float64,
+	smoother *decision.Smoother, incidents *incident_builder, votes *coordinator) {
+	incidents.gap = time.Duration(current().Record_Incident_Gap) * time.Second
+	smoother.Settings = decision.Configured(current())
+
+	// Decision Logic (smoothed over recent windows)
+	// 1. Ignore "empty" class
+	// 2. Check if confidence is above Trust threshold (for this model/class)
+	result := smoother.Update(predictions)
+	votes.report(window.sequence, name, result)
+	if !current().Reported(name) {
+		// Only scanned for ensembles
+		return
+	}
+	if result.Matched {
+		log.Info("SCANNER %s: MATCH found! Class: %s (Conf: %.4f) at %s+%ds",
+			name, result.Class, result.Confidence,
+			window.location.recording, window.location.offset)
+		detection := events.Detection{
+			Time:          window.location.started,
+			Model:         name,
+			Class:         result.Class,
+			Confidence:    result.Confidence,
+			Probabilities: result.Probabilities,
+			Recording:     window.location.recording,
+			Offset:        window.location.offset,
+		}
+		if err := ledger.Record_Detection(detection); err != nil {
+			log.Warn("SCANNER %s: Failed to save detection: %s", name, err)
+		}
+		save_incidents(name, incidents.match(detection))
+	} else {
+		log.Trace("SCANNER %s: No match. Top: %s (Conf: %.4f)", name, result.Class, result.Confidence)
+		save_incidents(name, incidents.advance(window.location.started))
+	}
+}
+
+// Log and record each finished incident
+func save_incidents(name string, incidents []events.Incident) {
+	for _, incident := range incidents {
+		log.Info("SCANNER %s: INCIDENT ended. Class: %s (Duration: %.0fs, Peak: %.4f)",
+			name, incident.Class, incident.Duration, incident.Peak)
+		if err := events.Record_Incident(incident); err != nil {
+			log.Warn("SCANNER %s: Failed to save incident: %s", name, err)
 		}
 	}
 }
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/decision"
+	"dtrack/events"
+	"dtrack/ledger"
+	"dtrack/log"
+
+	// Standard
+	"sync"
+	"time"
+)
+
+// Window waiting on the decision of each scanner it was sent to
+type pending_votes struct {
+	location  segment_location
+	waiting   map[string]bool
+	decisions map[string]decision.Decision
+}
+
+// Outcome of one ensemble, saved once the coordinator is unlocked
+type ensemble_result struct {
+	name      string
+	detection *events.Detection // Only if the ensemble matched
+	incidents []events.Incident // Closed by this window
+}
+
+// Combines scanner decisions for the same window (by sequence) with ensembles
+type coordinator struct {
+	lock      sync.Mutex
+	pending   map[uint64]*pending_votes
+	incidents map[string]*incident_builder // One per ensemble
+}
+
+// Create a coordinator with no pending windows
+func new_coordinator() *coordinator {
+	return &coordinator{
+		pending:   make(map[uint64]*pending_votes),
+		incidents: make(map[string]*incident_builder),
+	}
+}
+
+// Wait for a decision from each named scanner before voting on a window
+func (c *coordinator) expect(window prepared_window, names []string) {
+	if len(current().Record_Ensembles) == 0 || len(names) == 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	waiting := make(map[string]bool)
+	for _, name := range names {
+		waiting[name] = true
+	}
+	c.pending[window.sequence] = &pending_votes{
+		location:  window.location,
+		waiting:   waiting,
+		decisions: make(map[string]decision.Decision),
+	}
+}
+
+// Add the decision of one scanner; votes once every scanner has decided
+func (c *coordinator) report(sequence uint64, name string, result decision.Decision) {
+	c.lock.Lock()
+	votes, ok := c.pending[sequence]
+	if !ok || !votes.waiting[name] {
+		c.lock.Unlock()
+		return
+	}
+	delete(votes.waiting, name)
+	votes.decisions[name] = result
+	results := c.complete(sequence, votes)
+	c.lock.Unlock()
+
+	save_ensembles(results)
+}
+
+// A scanner will never decide a window (e.g. it was too busy to receive it)
+func (c *coordinator) skip(sequence uint64, name string) {
+	c.lock.Lock()
+	var results []ensemble_result
+	if votes, ok := c.pending[sequence]; ok {
+		delete(votes.waiting, name)
+		results = c.complete(sequence, votes)
+	}
+	c.lock.Unlock()
+
+	save_ensembles(results)
+}
+
+// A stopped scanner will never decide any pending window
+func (c *coordinator) forget(name string) {
+	c.lock.Lock()
+	var results []ensemble_result
+	for sequence, votes := range c.pending {
+		delete(votes.waiting, name)
+		results = append(results, c.complete(sequence, votes)...)
+	}
+	c.lock.Unlock()
+
+	save_ensembles(results)
+}
+
+// Vote on a window once nothing is waiting (lock must be held)
+// Returns the outcome of each ensemble, to be saved after unlocking.
+func (c *coordinator) complete(sequence uint64, votes *pending_votes) []ensemble_result {
+	if len(votes.waiting) > 0 {
+		return nil
+	}
+	delete(c.pending, sequence)
+
+	var results []ensemble_result
+	gap := time.Duration(current().Record_Incident_Gap) * time.Second
+	for _, ensemble := range current().Record_Ensembles {
+		incidents, ok := c.incidents[ensemble.Name]
+		if !ok {
+			incidents = new_incident_builder(gap)
+			c.incidents[ensemble.Name] = incidents
+		}
+		incidents.gap = gap
+
+		result := decision.Combine(ensemble, votes.decisions,
+			current().Trust(ensemble.Name, ensemble.Class))
+		if !result.Matched {
+			results = append(results, ensemble_result{
+				name:      ensemble.Name,
+				incidents: incidents.advance(votes.location.started),
+			})
+			continue
+		}
+		detection := events.Detection{
+			Time:          votes.location.started,
+			Model:         ensemble.Name,
+			Class:         result.Class,
+			Confidence:    result.Confidence,
+			Probabilities: result.Probabilities,
+			Recording:     votes.location.recording,
+			Offset:        votes.location.offset,
+		}
+		results = append(results, ensemble_result{
+			name:      ensemble.Name,
+			detection: &detection,
+			incidents: incidents.match(detection),
+		})
+	}
+	return results
+}
+
+// Close every open ensemble incident
+func (c *coordinator) flush() {
+	c.lock.Lock()
+	var results []ensemble_result
+	for name, incidents := range c.incidents {
+		results = append(results, ensemble_result{name: name, incidents: incidents.flush()})
+	}
+	c.lock.Unlock()
+
+	save_ensembles(results)
+}
+
+// Log and record ensemble detections, then the incidents they closed
+func save_ensembles(results []ensemble_result) {
+	for _, result := range results {
+		if detection := result.detection; detection != nil {
+			log.Info("ENSEMBLE %s: MATCH found! Class: %s (Conf: %.4f) at %s+%ds",
+				result.name, detection.Class, detection.Confidence,
+				detection.Recording, detection.Offset)
+			if err := ledger.Record_Detection(*detection); err != nil {
+				log.Warn("ENSEMBLE %s: Failed to save detection: %s", result.name, err)
+			}
+		}
+		save_incidents(result.name, result.incidents)
+	}
+}
@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/decision"
+	"dtrack/events"
+	"dtrack/state"
+
+	// Standard
+	"testing"
+	"time"
+)
+
+// Ensembles vote only after every scanner decided (or skipped) a window
+func TestCoordinator_Votes(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: t.TempDir()}
+	cfg := state.Runtime
+	cfg.Record_Inspect_Trust = 0.5
+	cfg.Record_Ensembles = []state.Ensemble{
+		{Name: "dogs", Class: "bark", Rule: state.Rule_All, Models: []string{"a", "b"}},
+	}
+	settings.Store(&cfg)
+
+	barking := decision.Decision{
+		Probabilities: map[string]float64{"bark": 0.9, "empty": 0.1},
+		Open:          map[string]bool{"bark": true},
+	}
+	votes := new_coordinator()
+	started := time.Now()
+	for sequence := uint64(0); sequence < 3; sequence++ {
+		votes.expect(prepared_window{
+			sequence: sequence,
+			location: segment_location{started: started.Add(time.Duration(sequence) * time.Second)},
+		}, []string{"a", "b"})
+	}
+
+	// Window 0: both agree, but only after the second decision
+	votes.report(0, "a", barking)
+	if detections, _ := events.Load_Detections(); len(detections) != 0 {
+		t.Fatalf("Voted before every scanner decided: %+v", detections)
+	}
+	votes.report(0, "b", barking)
+
+	// Window 1: b was too busy, so the ensemble cannot agree
+	votes.report(1, "a", barking)
+	votes.skip(1, "b")
+
+	// Window 2: b stopped before deciding
+	votes.report(2, "a", barking)
+	votes.forget("b")
+	votes.flush()
+
+	detections, err := events.Load_Detections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detections) != 1 || detections[0].Model != "dogs" || !detections[0].Time.Equal(started) {
+		t.Errorf("Expected a single ensemble detection at window 0, got %+v", detections)
+	}
+	if len(votes.pending) != 0 {
+		t.Errorf("Expected no pending windows, got %d", len(votes.pending))
+	}
+}
+
+// Segment ids restart with the stream; votes still go to the window they decided
+func TestCoordinator_Restart(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: t.TempDir()}
+	cfg := state.Runtime
+	cfg.Record_Inspect_Trust = 0.5
+	cfg.Record_Ensembles = []state.Ensemble{
+		{Name: "dogs", Class: "bark", Rule: state.Rule_All, Models: []string{"a", "b"}},
+	}
+	settings.Store(&cfg)
+
+	// Scanners added directly; update() would start inference
+	pool := new_scanner_pool()
+	a := &scanner{windows: make(chan prepared_window, 2)}
+	b := &scanner{windows: make(chan prepared_window, 2)}
+	pool.scanners["a"], pool.scanners["b"] = a, b
+
+	// Segment 0 before and after a reconnect
+	started := time.Now()
+	pool.send(prepared_window{count: 0, location: segment_location{started: started}})
+	pool.send(prepared_window{count: 0, location: segment_location{started: started.Add(time.Minute)}})
+
+	barking := decision.Decision{
+		Probabilities: map[string]float64{"bark": 0.9, "empty": 0.1},
+		Open:          map[string]bool{"bark": true},
+	}
+	quiet := decision.Decision{Probabilities: map[string]float64{"bark": 0.1, "empty": 0.9}}
+	first, second := <-a.windows, <-a.windows
+	pool.votes.report(first.sequence, "a", barking)
+	pool.votes.report(second.sequence, "a", quiet)
+	first, second = <-b.windows, <-b.windows
+	pool.votes.report(first.sequence, "b", barking)
+	pool.votes.report(second.sequence, "b", barking)
+
+	detections, err := events.Load_Detections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detections) != 1 || !detections[0].Time.Equal(started) {
+		t.Errorf("Expected a single ensemble detection before the reconnect, got %+v", detections)
+	}
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/model"
+
+	// Standard
+	"sort"
+	"time"
+)
+
+// Merges matches from a single scanner into incidents, one per class
+type incident_builder struct {
+	gap  time.Duration
+	open map[string]*events.Incident
+}
+
+// Create a builder that closes incidents after gap without a match
+func new_incident_builder(gap time.Duration) *incident_builder {
+	return &incident_builder{
+		gap:  gap,
+		open: make(map[string]*events.Incident),
+	}
+}
+
+// Add a detection to its open incident, or begin a new incident
+// Returns any incident closed by the new detection.
+func (b *incident_builder) match(detection events.Detection) []events.Incident {
+	closed := b.advance(detection.Time)
+	end := detection.Time.Add(model.SegmentSize * time.Second)
+
+	incident, ok := b.open[detection.Class]
+	if !ok {
+		b.open[detection.Class] = &events.Incident{
+			Model:     detection.Model,
+			Class:     detection.Class,
+			Start:     detection.Time,
+			End:       end,
+			Duration:  end.Sub(detection.Time).Seconds(),
+			Peak:      detection.Confidence,
+			Mean:      detection.Confidence,
+			Matches:   1,
+			Recording: detection.Recording,
+			Offset:    detection.Offset,
+		}
+		return closed
+	}
+
+	// Extend the open incident
+	incident.Matches++
+	incident.Mean += (detection.Confidence - incident.Mean) / float64(incident.Matches)
+	if detection.Confidence > incident.Peak {
+		incident.Peak = detection.Confidence
+	}
+	if end.After(incident.End) {
+		incident.End = end
+	}
+	incident.Duration = incident.End.Sub(incident.Start).Seconds()
+	return closed
+}
+
+// Close every incident that has not seen a match since (now - gap)
+func (b *incident_builder) advance(now time.Time) []events.Incident {
+	var closed []events.Incident
+	for class, incident := range b.open {
+		if now.Sub(incident.End) >= b.gap {
+			closed = append(closed, *incident)
+			delete(b.open, class)
+		}
+	}
+	return by_start(closed)
+}
+
+// Close all open incidents
+func (b *incident_builder) flush() []events.Incident {
+	var closed []events.Incident
+	for class, incident := range b.open {
+		closed = append(closed, *incident)
+		delete(b.open, class)
+	}
+	return by_start(closed)
+}
+
+// Order closed incidents by start time (then class), independent of map order
+func by_start(closed []events.Incident) []events.Incident {
+	sort.Slice(closed, func(i, j int) bool {
+		if !closed[i].Start.Equal(closed[j].Start) {
+			return closed[i].Start.Before(closed[j].Start)
+		}
+		return closed[i].Class < closed[j].Class
+	})
+	return closed
+}
@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/events"
+
+	// Standard
+	"testing"
+	"time"
+)
+
+// Build a detection at a number of seconds after a fixed start time
+func detection_at(second int, class string, confidence float64) events.Detection {
+	start := time.Date(2025, 6, 1, 22, 0, 0, 0, time.UTC)
+	return events.Detection{
+		Time:       start.Add(time.Duration(second) * time.Second),
+		Model:      "dog",
+		Class:      class,
+		Confidence: confidence,
+		Recording:  "2025-06-01_220000.mkv",
+		Offset:     second,
+	}
+}
+
+// Matches separated by less than the gap become a single incident
+func TestIncidentBuilder_Merge(t *testing.T) {
+	builder := new_incident_builder(5 * time.Second)
+
+	var closed []events.Incident
+	for second, confidence := range []float64{0.6, 0.8, 0.7, 0.9} {
+		closed = append(closed, builder.match(detection_at(second, "bark", confidence))...)
+	}
+	// Match at second 12 begins a new incident (last window ended at 5)
+	closed = append(closed, builder.match(detection_at(12, "bark", 0.55))...)
+	closed = append(closed, builder.flush()...)
+
+	if len(closed) != 2 {
+		t.Fatalf("Expected 2 incidents, got %d: %+v", len(closed), closed)
+	}
+	first := closed[0]
+	if first.Matches != 4 || first.Duration != 5 {
+		t.Errorf("Unexpected incident size: %d matches, %.0fs", first.Matches, first.Duration)
+	}
+	if first.Peak != 0.9 {
+		t.Errorf("Expected peak 0.9, got %f", first.Peak)
+	}
+	if first.Mean < 0.7499 || first.Mean > 0.7501 {
+		t.Errorf("Expected mean 0.75, got %f", first.Mean)
+	}
+	if first.Offset != 0 || closed[1].Offset != 12 {
+		t.Errorf("Incidents should keep the offset of their first match")
+	}
+}
+
+// Classes are tracked independently
+func TestIncidentBuilder_Classes(t *testing.T) {
+	builder := new_incident_builder(5 * time.Second)
+	builder.match(detection_at(0, "bark", 0.6))
+	builder.match(detection_at(1, "howl", 0.6))
+	builder.match(detection_at(2, "bark", 0.6))
+
+	closed := builder.flush()
+	if len(closed) != 2 {
+		t.Fatalf("Expected 2 incidents, got %d", len(closed))
+	}
+}
+
+// Quiet windows close incidents once the gap has passed
+func TestIncidentBuilder_Advance(t *testing.T) {
+	builder := new_incident_builder(5 * time.Second)
+	builder.match(detection_at(0, "bark", 0.6))
+
+	if closed := builder.advance(detection_at(6, "", 0).Time); len(closed) != 0 {
+		t.Errorf("Incident closed before gap passed")
+	}
+	if closed := builder.advance(detection_at(7, "", 0).Time); len(closed) != 1 {
+		t.Errorf("Incident not closed after gap passed")
+	}
+	if closed := builder.flush(); len(closed) != 0 {
+		t.Errorf("Closed incident was returned twice")
+	}
+}
+
+// Incidents closed together are returned in order of their start
+func TestIncidentBuilder_Order(t *testing.T) {
+	for run := 0; run < 20; run++ {
+		builder := new_incident_builder(5 * time.Second)
+		for second, class := range []string{"whine", "bark", "howl", "growl"} {
+			builder.match(detection_at(second, class, 0.6))
+		}
+
+		closed := builder.flush()
+		for i, class := range []string{"whine", "bark", "howl", "growl"} {
+			if closed[i].Class != class {
+				t.Fatalf("Expected %s at position %d, got %+v", class, i, closed)
+			}
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/log"
+	"dtrack/model"
+	"dtrack/state"
+
+	// Standard
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Inspection settings (models and thresholds); replaced on SIGHUP
+var settings atomic.Pointer[state.Application_Configuration]
+
+// Current inspection settings
+func current() *state.Application_Configuration {
+	return settings.Load()
+}
+
+// Time between checks for retrained models
+const ModelPoll = 30 * time.Second
+
+// Single running scanner, and the detector it inspects windows with
+type scanner struct {
+	windows  chan prepared_window
+	detector atomic.Pointer[model.Detector]
+	version  string      // Detector when last loaded (see detector_version)
+	dropped  *events.Gap // Windows missed since the scanner last kept up
+	disabled atomic.Bool // Model failed; no windows until its files change
+	gap_lock sync.Mutex  // Guards dropped (the pool and the scanner both drop windows)
+}
+
+// Running segment scanners, one per inspection model
+type scanner_pool struct {
+	lock     sync.Mutex
+	scanners map[string]*scanner
+	running  sync.WaitGroup
+	votes    *coordinator
+	sequence atomic.Uint64 // Last window sent; never restarts
+}
+
+// Create an empty pool; scanners are started by update
+func new_scanner_pool() *scanner_pool {
+	return &scanner_pool{
+		scanners: make(map[string]*scanner),
+		votes:    new_coordinator(),
+	}
+}
+
+// Start scanners for new models, and stop scanners for removed models
+func (p *scanner_pool) update(models []string, backlog int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	wanted := make(map[string]bool)
+	for _, name := range models {
+		wanted[name] = true
+	}
+	for name, scanner := range p.scanners {
+		if !wanted[name] {
+			log.Info("Stopping scanner: %s", name)
+			scanner.caught_up()
+			close(scanner.windows)
+			delete(p.scanners, name)
+		}
+	}
+	for _, name := range models {
+		if _, ok := p.scanners[name]; ok {
+			continue
+		}
+		log.Debug("Starting scanner: %s", name)
+		scanner := &scanner{
+			windows: make(chan prepared_window, backlog),
+			version: detector_version(name),
+		}
+		p.scanners[name] = scanner
+		p.running.Add(1)
+		go func() {
+			defer p.running.Done()
+			scan_segments(name, scanner, p.votes)
+			p.votes.forget(name)
+		}()
+	}
+}
+
+// Send a window to every scanner without waiting; busy scanners miss it
+func (p *scanner_pool) send(window prepared_window) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	window.sequence = p.sequence.Add(1)
+	names := make([]string, 0, len(p.scanners))
+	for name := range p.scanners {
+		names = append(names, name)
+	}
+	p.votes.expect(window, names)
+
+	for name, scanner := range p.scanners {
+		// Disabled scanners miss every window, without waiting for a backlog
+		if scanner.disabled.Load() {
+			p.votes.skip(window.sequence, name)
+			scanner.drop(name, window)
+			continue
+		}
+		select {
+		// Send segment to individual scanner
+		case scanner.windows <- window:
+		default:
+			log.Warn("Scanner Blocked: %s", name)
+			stats.blocked.Add(1)
+			p.votes.skip(window.sequence, name)
+			scanner.drop(name, window)
+			continue
+		}
+		scanner.caught_up()
+	}
+}
+
+// Add a window the scanner never inspected to its current gap
+// Windows already queued when a scanner is disabled may be older than the gap.
+func (s *scanner) drop(name string, window prepared_window) {
+	s.gap_lock.Lock()
+	defer s.gap_lock.Unlock()
+	if s.dropped == nil || window.location.started.Before(s.dropped.Start) {
+		if s.dropped == nil {
+			s.dropped = &events.Gap{Model: name}
+		}
+		s.dropped.Start = window.location.started
+		s.dropped.Recording = window.location.recording
+		s.dropped.Offset = window.location.offset
+	}
+	s.dropped.Windows++
+	if end := window.location.started.Add(model.SegmentSize * time.Second); end.After(s.dropped.End) {
+		s.dropped.End = end
+	}
+	s.dropped.Duration = s.dropped.End.Sub(s.dropped.Start).Seconds()
+}
+
+// Record the current gap (if any) once the scanner receives windows again
+func (s *scanner) caught_up() {
+	s.gap_lock.Lock()
+	defer s.gap_lock.Unlock()
+	if s.dropped == nil {
+		return
+	}
+	log.Warn("Scanner %s missed %d windows (%.0fs)", s.dropped.Model, s.dropped.Windows, s.dropped.Duration)
+	if err := events.Record_Gap(*s.dropped); err != nil {
+		log.Warn("Scanner %s: Failed to save gap: %s", s.dropped.Model, err)
+	}
+	s.dropped = nil
+}
+
+// Returns true if no scanners are running
+func (p *scanner_pool) empty() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.scanners) == 0
+}
+
+// Windows waiting in each scanner's backlog
+func (p *scanner_pool) backlog() map[string]int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	waiting := make(map[string]int)
+	for name, scanner := range p.scanners {
+		waiting[name] = len(scanner.windows)
+	}
+	return waiting
+}
+
+// Stop every scanner, waiting for each to save open incidents
+func (p *scanner_pool) stop() {
+	p.update(nil, 0)
+	p.running.Wait()
+	p.votes.flush()
+}
+
+// Check for retrained models until the daemon exits
+func (p *scanner_pool) watch_models() {
+	for {
+		time.Sleep(ModelPoll)
+		p.check_models()
+	}
+}
+
+// Swap in any model whose files (or detector settings) changed since it was loaded
+func (p *scanner_pool) check_models() {
+	p.lock.Lock()
+	changed := make(map[string]*scanner)
+	for name, scanner := range p.scanners {
+		if version := detector_version(name); version != scanner.version {
+			scanner.version = version
+			changed[name] = scanner
+		}
+	}
+	p.lock.Unlock()
+
+	// Loading happens outside the lock; scanners keep running meanwhile
+	for name, scanner := range changed {
+		swap_detector(name, scanner)
+	}
+}
+
+// Create the configured detector of a model (checking ONNX models)
+func new_detector(name string) (model.Detector, error) {
+	return model.New_Detector(current().Detector(name), model_path(name))
+}
+
+// Create, validate, and swap in a new detector for a running scanner
+// The current detector is kept if the new one is broken (or half-written).
+func swap_detector(name string, scanner *scanner) {
+	next, err := new_detector(name)
+	if err != nil {
+		log.Warn("Model %s not reloaded: %s", name, err)
+		return
+	}
+
+	previous := scanner.detector.Swap(&next)
+	old_version := "none"
+	if previous != nil {
+		old_version = (*previous).Describe()
+	}
+	log.Info("Model %s reloaded: %s -> %s", name, old_version, next.Describe())
+	if scanner.disabled.CompareAndSwap(true, false) {
+		log.Info("SCANNER %s: Enabled again", name)
+	}
+}
+
+// Stop inspecting with a failed model; recording (and other scanners) continue
+// The scanner is enabled again once a working model is swapped in.
+func (s *scanner) disable(name string, err error) {
+	if s.disabled.CompareAndSwap(false, true) {
+		log.Warn("SCANNER %s: Disabled until its model files change: %s", name, err)
+		stats.disabled.Add(1)
+	}
+}
+
+// Settings of a detector, or the modification time and size of an ONNX model's files
+// ONNX models are empty if either file is missing.
+func detector_version(name string) string {
+	detector := current().Detector(name)
+	if detector.Type != state.Detector_ONNX {
+		return detector.Type + ":" + string(detector.Params)
+	}
+	files := ""
+	for _, path := range []string{model_path(name), labels_path(name)} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		files += fmt.Sprintf("%d:%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return files
+}
+
+// Reload inspection models and thresholds from the configuration file
+// Recording options (devices, durations, etc.) require a restart.
+func reload(pool *scanner_pool) {
+	cfg, err := state.Read_Configuration(state.Config_Path)
+	if err != nil {
+		log.Warn("Reload failed (%s); keeping current configuration", err)
+		return
+	}
+
+	// Skip ONNX models that do not exist, rather than failing their scanner
+	models := []state.Detector{}
+	for _, detector := range cfg.Record_Inspect_Models {
+		if missing_model(&cfg, []string{detector.Name}) != "" {
+			log.Warn("Model not found; skipping: %s", model_path(detector.Name))
+			continue
+		}
+		models = append(models, detector)
+	}
+	cfg.Record_Inspect_Models = models
+
+	// Skip ensembles missing any of their models
+	ensembles := []state.Ensemble{}
+	for _, ensemble := range cfg.Record_Ensembles {
+		if missing := missing_model(&cfg, ensemble.Models); missing != "" {
+			log.Warn("Ensemble %s model not found; skipping: %s", ensemble.Name, model_path(missing))
+			continue
+		}
+		ensembles = append(ensembles, ensemble)
+	}
+	cfg.Record_Ensembles = ensembles
+	settings.Store(&cfg)
+
+	// Audio is only captured for inspection if models were set at startup
+	scanned := cfg.Scanned_Models()
+	if state.Runtime.Has_Models {
+		pool.update(scanned, cfg.Record_Inspect_Backlog)
+		pool.check_models()
+	} else if len(scanned) > 0 {
+		log.Warn("Monitor started without models; restart to begin inspection")
+	}
+	log.Info("Configuration reloaded: %d models, %d ensembles, trust %.2f, silence %.1f dBFS",
+		len(scanned), len(ensembles), cfg.Record_Inspect_Trust, cfg.Record_Inspect_Silence)
+}
+
+// First ONNX model (if any) without a model file
+func missing_model(cfg *state.Application_Configuration, models []string) string {
+	for _, name := range models {
+		if cfg.Detector(name).Type != state.Detector_ONNX {
+			continue
+		}
+		if _, err := os.Stat(model_path(name)); err != nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// Path to an inspection model
+func model_path(name string) string {
+	return state.Runtime.Workspace + "/models/" + name + ".onnx"
+}
+
+// Path to the labels of an inspection model
+func labels_path(name string) string {
+	return state.Runtime.Workspace + "/models/" + name + ".labels"
+}
@@ -0,0 +1,246 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/model"
+	"dtrack/state"
+
+	// Standard
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Reloading swaps thresholds, skips missing models, and survives bad files
+func TestReload(t *testing.T) {
+	workspace := t.TempDir()
+	state.Runtime = state.Application_Configuration{Workspace: workspace}
+	state.Config_Path = filepath.Join(workspace, "config.json")
+	cfg := state.Runtime
+	settings.Store(&cfg)
+
+	config := `{"inspect_trust": 0.8, "incident_gap": 30, "inspect_models": ["missing"]}`
+	if err := os.WriteFile(state.Config_Path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reload(new_scanner_pool())
+	if current().Record_Inspect_Trust != 0.8 || current().Record_Incident_Gap != 30 {
+		t.Errorf("Thresholds not reloaded: %+v", current())
+	}
+	if len(current().Record_Inspect_Models) != 0 {
+		t.Errorf("Expected missing model to be skipped, got %v", current().Record_Inspect_Models)
+	}
+
+	// Broken configuration keeps the current settings
+	if err := os.WriteFile(state.Config_Path, []byte("{not json}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reload(new_scanner_pool())
+	if current().Record_Inspect_Trust != 0.8 {
+		t.Errorf("Expected previous settings to remain, got trust %.2f", current().Record_Inspect_Trust)
+	}
+}
+
+// Changed model files are reloaded, but a broken model never replaces a working one
+func TestCheckModels_Broken(t *testing.T) {
+	workspace := t.TempDir()
+	state.Runtime = state.Application_Configuration{Workspace: workspace}
+	cfg := state.Runtime
+	settings.Store(&cfg)
+	if err := os.MkdirAll(filepath.Join(workspace, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(onnx string) {
+		if err := os.WriteFile(model_path("dog"), []byte(onnx), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(labels_path("dog"), []byte(`["dog", "empty"]`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("first")
+
+	// Scanner added directly; update() would start inference
+	pool := new_scanner_pool()
+	working := model.Detector(&model.OnnxModel{Hash: "working"})
+	dog := &scanner{version: detector_version("dog")}
+	dog.detector.Store(&working)
+	pool.scanners["dog"] = dog
+
+	// Unchanged files are left alone
+	pool.check_models()
+	if *dog.detector.Load() != working {
+		t.Fatal("Model replaced without any change")
+	}
+
+	// Retrained (but broken) model is noticed and rejected
+	write("retrained, but broken")
+	pool.check_models()
+	if dog.version != detector_version("dog") {
+		t.Error("Changed model files were not noticed")
+	}
+	if *dog.detector.Load() != working {
+		t.Error("Broken model replaced the working model")
+	}
+}
+
+// Windows waiting for a busy scanner are batched, up to model.BatchMax
+func TestNextBatch(t *testing.T) {
+	windows := make(chan prepared_window, model.BatchMax+2)
+	for count := uint(1); count <= model.BatchMax+1; count++ {
+		windows <- prepared_window{count: count}
+	}
+	batch := next_batch(prepared_window{count: 0}, windows)
+	if len(batch) != model.BatchMax || batch[0].count != 0 || batch[model.BatchMax-1].count != model.BatchMax-1 {
+		t.Errorf("Expected windows 0-%d, got %d windows", model.BatchMax-1, len(batch))
+	}
+
+	// Caught up (and stopped) scanners take what is left
+	close(windows)
+	if batch := next_batch(prepared_window{}, windows); len(batch) != 3 {
+		t.Errorf("Expected 3 windows, got %d", len(batch))
+	}
+}
+
+// Windows dropped in a row are saved as a single gap once the scanner catches up
+func TestSend_Gap(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: t.TempDir()}
+	cfg := state.Runtime
+	settings.Store(&cfg)
+
+	// Scanner added directly; update() would start inference
+	pool := new_scanner_pool()
+	dog := &scanner{windows: make(chan prepared_window, 1)}
+	pool.scanners["dog"] = dog
+
+	started := time.Now()
+	send := func(count uint) {
+		pool.send(prepared_window{
+			count:    count,
+			location: segment_location{started: started.Add(time.Duration(count) * time.Second)},
+		})
+	}
+	send(0)
+	send(1)
+	send(2)
+	<-dog.windows
+	send(3)
+
+	gaps, err := events.Load_Gaps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 1 || gaps[0].Windows != 2 || gaps[0].Duration != 3 {
+		t.Errorf("Expected a 2 window (3 second) gap, got %+v", gaps)
+	}
+}
+
+// A broken model disables only its scanner; missed windows are saved as a gap
+func TestScanner_Disabled(t *testing.T) {
+	workspace := t.TempDir()
+	state.Runtime = state.Application_Configuration{Workspace: workspace}
+	cfg := state.Runtime
+	settings.Store(&cfg)
+	if err := os.MkdirAll(filepath.Join(workspace, "models"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(model_path("dog"), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(labels_path("dog"), []byte(`["dog", "empty"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := new_scanner_pool()
+	dog := &scanner{windows: make(chan prepared_window, 1)}
+	pool.scanners["dog"] = dog
+	started := time.Now()
+	send := func(count uint) {
+		pool.send(prepared_window{
+			count:    count,
+			location: segment_location{started: started.Add(time.Duration(count) * time.Second)},
+		})
+	}
+
+	// Scanner stops (rather than the daemon) after failing to load
+	send(0)
+	close(dog.windows)
+	scan_segments("dog", dog, pool.votes)
+	if !dog.disabled.Load() {
+		t.Fatal("Expected scanner to be disabled")
+	}
+
+	// Every window is missed until the scanner is enabled again
+	dog.windows = make(chan prepared_window, 1)
+	send(1)
+	send(2)
+	if len(dog.windows) != 0 {
+		t.Error("Disabled scanner received a window")
+	}
+	dog.disabled.Store(false)
+	send(3)
+
+	gaps, err := events.Load_Gaps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The window queued before the model failed is saved when the scanner stops
+	if len(gaps) != 2 || gaps[0].Windows != 1 || !gaps[0].Start.Equal(started) || gaps[1].Windows != 2 {
+		t.Errorf("Expected a 1 window gap, then a 2 window gap, got %+v", gaps)
+	}
+}
+
+// Windows queued before a scanner was disabled are older than the gap they join
+func TestDrop_Older(t *testing.T) {
+	dog := &scanner{}
+	started := time.Now()
+	window := func(count uint) prepared_window {
+		return prepared_window{
+			count:    count,
+			location: segment_location{started: started.Add(time.Duration(count) * time.Second)},
+		}
+	}
+	dog.drop("dog", window(2))
+	dog.drop("dog", window(0))
+	dog.drop("dog", window(1))
+	if dog.dropped.Windows != 3 || !dog.dropped.Start.Equal(started) || dog.dropped.Duration != 4 {
+		t.Errorf("Expected a 3 window (4 second) gap from window 0, got %+v", dog.dropped)
+	}
+}
+
+// Rule-based detectors need no model files, and are swapped when their settings change
+func TestCheckModels_Settings(t *testing.T) {
+	workspace := t.TempDir()
+	state.Runtime = state.Application_Configuration{Workspace: workspace}
+	state.Config_Path = filepath.Join(workspace, "config.json")
+	write := func(threshold string) {
+		config := `{"inspect_models": [{"name": "loud", "type": "loudness", "params": {"threshold": ` + threshold + `}}]}`
+		if err := os.WriteFile(state.Config_Path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("-20")
+	pool := new_scanner_pool()
+	reload(pool)
+	if len(current().Record_Inspect_Models) != 1 {
+		t.Fatalf("Expected loudness detector to be kept, got %v", current().Record_Inspect_Models)
+	}
+
+	// Scanner added directly; update() would start inference
+	loud := &scanner{version: detector_version("loud")}
+	swap_detector("loud", loud)
+	if loud.detector.Load() == nil || (*loud.detector.Load()).Describe() != "loud: rms above -20.0 dBFS" {
+		t.Fatalf("Unexpected detector: %v", loud.detector.Load())
+	}
+	pool.scanners["loud"] = loud
+
+	// Monitor started with models; the running scanner is kept
+	state.Runtime.Has_Models = true
+	write("-10")
+	reload(pool)
+	if described := (*loud.detector.Load()).Describe(); described != "loud: rms above -10.0 dBFS" {
+		t.Errorf("Expected changed threshold to be swapped in, got %s", described)
+	}
+}
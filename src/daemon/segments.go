@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+	"dtrack/log"
+
+	// Standard
+	"time"
+)
+
+// Time between checks for a new recording from the segment muxer
+const SegmentPoll = 5 * time.Second
+
+// Recordings written by a single ffmpeg capture
+type segment_tracker struct {
+	started  time.Time
+	finished map[string]bool
+}
+
+// Track recordings written since started
+func new_segment_tracker(started time.Time) *segment_tracker {
+	return &segment_tracker{
+		started:  started.Truncate(time.Second),
+		finished: make(map[string]bool),
+	}
+}
+
+// Ledger every recording that ffmpeg is done writing
+// All but the newest recording are finished, unless ffmpeg has exited.
+func (s *segment_tracker) update(exited bool) {
+	recordings, err := ffmpeg.List_Recordings()
+	if err != nil {
+		log.Warn("Unable to list recordings: %s", err)
+		return
+	}
+	for _, recording := range s.newly_finished(recordings, exited) {
+		log.Debug("Recording finished: %s", recording.Name)
+		stats.report()
+		ledger_pending.Add(1)
+		go ledger_recording(recording.Path)
+	}
+}
+
+// Recordings from this capture that finished since the last update
+func (s *segment_tracker) newly_finished(recordings []ffmpeg.Recording, exited bool) []ffmpeg.Recording {
+	captured := []ffmpeg.Recording{}
+	for _, recording := range recordings {
+		if !recording.Start.Before(s.started) {
+			captured = append(captured, recording)
+		}
+	}
+	if !exited && len(captured) > 0 {
+		captured = captured[:len(captured)-1]
+	}
+
+	finished := []ffmpeg.Recording{}
+	for _, recording := range captured {
+		if !s.finished[recording.Name] {
+			s.finished[recording.Name] = true
+			finished = append(finished, recording)
+		}
+	}
+	return finished
+}
@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+	"dtrack/log"
+
+	// Standard
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Handle signals for the life of the daemon
+//
+//	SIGINT, SIGTERM: Finish current recording (second time: exit immediately)
+//	SIGHUP:          Reload inspection models and thresholds
+//	SIGUSR1:         Log a status snapshot
+func handle_signals(stopping chan struct{}, pool *scanner_pool) {
+	sig_chan := make(chan os.Signal, 1)
+	signal.Notify(sig_chan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
+	for sig := range sig_chan {
+		switch sig {
+		case syscall.SIGHUP:
+			reload(pool)
+		case syscall.SIGUSR1:
+			status(pool)
+		default:
+			if closed(stopping) {
+				log.Die("Second %s received. Terminating immediately.", sig)
+			}
+			log.Info("Received %s: Finishing current recording.", sig)
+			close(stopping)
+		}
+	}
+}
+
+// Log a snapshot of the running daemon
+func status(pool *scanner_pool) {
+	recording := "none"
+	if recordings, err := ffmpeg.List_Recordings(); err == nil && len(recordings) > 0 {
+		recording = recordings[len(recordings)-1].Name
+	}
+	log.Info("Status: up %s, %d captures (%d failed), recording to %s",
+		time.Since(stats.started).Round(time.Second),
+		stats.captures.Load(), stats.failures.Load(), recording)
+	log.Info("Status: %d windows, %d silent, %d blocked, %d batched",
+		stats.windows.Load(), stats.silent.Load(), stats.blocked.Load(), stats.batched.Load())
+
+	backlog := pool.backlog()
+	names := make([]string, 0, len(backlog))
+	for name := range backlog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Info("Status: scanner %s has %d/%d windows waiting",
+			name, backlog[name], current().Record_Inspect_Backlog)
+	}
+}
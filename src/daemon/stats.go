@@ -0,0 +1,31 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/log"
+
+	// Standard
+	"sync/atomic"
+	"time"
+)
+
+// Running totals for the current daemon session
+type daemon_stats struct {
+	started  time.Time     // Set once, before any other use
+	captures atomic.Uint64 // ffmpeg processes started
+	failures atomic.Uint64 // ffmpeg processes that stopped unexpectedly
+	windows  atomic.Uint64 // Check windows assembled
+	silent   atomic.Uint64 // Windows skipped by the energy gate
+	blocked  atomic.Uint64 // Windows dropped because a scanner was busy
+	batched  atomic.Uint64 // Windows taken at once by scanners that fell behind
+	disabled atomic.Uint64 // Scanners disabled by a failed model
+}
+
+// Shared by the recorder, converter, and scanners
+var stats daemon_stats
+
+// Log a summary of current session statistics
+func (s *daemon_stats) report() {
+	log.Debug("Stats: %d windows, %d silent, %d blocked, %d batched, %d disabled",
+		s.windows.Load(), s.silent.Load(), s.blocked.Load(), s.batched.Load(), s.disabled.Load())
+}
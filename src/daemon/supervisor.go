@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/log"
+
+	// Standard
+	"io"
+	"time"
+)
+
+// Shortest and longest pause before restarting a failed capture
+const (
+	RestartDelay    = 1 * time.Second
+	RestartMaxDelay = 5 * time.Minute
+)
+
+// Capture that keeps running this long has recovered from an outage
+const CaptureHealthy = 30 * time.Second
+
+// Tracks consecutive capture failures, and the outage they cause
+type capture_supervisor struct {
+	max_failures int // Give up after this many failures (0 = never)
+	failures     int
+	outage       *events.Outage
+}
+
+// Supervise captures, giving up after max_failures in a row
+func new_capture_supervisor(max_failures int) *capture_supervisor {
+	return &capture_supervisor{max_failures: max_failures}
+}
+
+// Note a capture that stopped unexpectedly
+// Returns the pause before restarting, or false once max_failures is reached.
+func (s *capture_supervisor) failed(when time.Time, reason error) (time.Duration, bool) {
+	s.failures++
+	if s.outage == nil {
+		s.outage = &events.Outage{Start: when, Reason: reason.Error()}
+	}
+	s.outage.Failures = s.failures
+	if s.max_failures > 0 && s.failures >= s.max_failures {
+		return 0, false
+	}
+
+	// Exponential backoff
+	delay := RestartDelay
+	for i := 1; i < s.failures && delay < RestartMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, RestartMaxDelay), true
+}
+
+// Note a capture that has been running since started without problems
+func (s *capture_supervisor) recovered(started time.Time) {
+	s.failures = 0
+	s.finish(started)
+}
+
+// Save any outage in progress, ending at when
+func (s *capture_supervisor) finish(when time.Time) {
+	if s.outage == nil {
+		return
+	}
+	s.outage.End = when
+	s.outage.Duration = when.Sub(s.outage.Start).Seconds()
+	log.Warn("Capture outage: %.0f seconds after %d failures (%s)",
+		s.outage.Duration, s.outage.Failures, s.outage.Reason)
+	if err := events.Record_Outage(*s.outage); err != nil {
+		log.Warn("Failed to save outage: %s", err)
+	}
+	s.outage = nil
+}
+
+// Keep the daemon stream flowing into consume, restarting it whenever it stops
+// Closing the old stream makes the running capture fail (and restart) too.
+func supervise_stream(stream *io.PipeReader, timeline *stream_timeline, consume func(*io.PipeReader) error) {
+	for {
+		err := consume(stream)
+		log.Warn("Audio stream stopped: %v", err)
+		stream.CloseWithError(err)
+		time.Sleep(RestartDelay)
+
+		var writer *io.PipeWriter
+		stream, writer = io.Pipe()
+		timeline.connect(writer)
+	}
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/state"
+
+	// Standard
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// Restarts wait longer after each failure, up to RestartMaxDelay
+func TestCaptureSupervisor_Backoff(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: t.TempDir()}
+	supervisor := new_capture_supervisor(0)
+	failure := errors.New("device unplugged")
+	now := time.Now()
+
+	expected := []time.Duration{1, 2, 4, 8, 16}
+	for i, want := range expected {
+		delay, retry := supervisor.failed(now, failure)
+		if !retry || delay != want*time.Second {
+			t.Errorf("Failure %d: expected %s, got %s (retry: %t)", i+1, want*time.Second, delay, retry)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		supervisor.failed(now, failure)
+	}
+	if delay, retry := supervisor.failed(now, failure); !retry || delay != RestartMaxDelay {
+		t.Errorf("Expected %s after many failures, got %s", RestartMaxDelay, delay)
+	}
+}
+
+// Consecutive failures form a single outage, saved once capture recovers
+func TestCaptureSupervisor_Outage(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: t.TempDir()}
+	supervisor := new_capture_supervisor(3)
+	start := time.Date(2025, 6, 1, 22, 0, 0, 0, time.UTC)
+
+	supervisor.failed(start, errors.New("device unplugged"))
+	supervisor.failed(start.Add(time.Second), errors.New("no such device"))
+	supervisor.recovered(start.Add(3 * time.Second))
+
+	outages, err := events.Load_Outages()
+	if err != nil {
+		t.Fatalf("Load_Outages failed: %v", err)
+	}
+	if len(outages) != 1 {
+		t.Fatalf("Expected 1 outage, got %d", len(outages))
+	}
+	outage := outages[0]
+	if !outage.Start.Equal(start) || outage.Duration != 3 || outage.Failures != 2 {
+		t.Errorf("Unexpected outage: %+v", outage)
+	}
+	if outage.Reason != "device unplugged" {
+		t.Errorf("Expected first failure as reason, got: %s", outage.Reason)
+	}
+
+	// Recovery resets the failure count
+	supervisor.failed(start.Add(time.Minute), errors.New("device unplugged"))
+	if _, retry := supervisor.failed(start.Add(time.Minute), errors.New("device unplugged")); !retry {
+		t.Error("Expected retry after recovery")
+	}
+	if _, retry := supervisor.failed(start.Add(time.Minute), errors.New("device unplugged")); retry {
+		t.Error("Expected supervisor to give up after 3 consecutive failures")
+	}
+}
+
+// A failed segmenter is restarted on a new stream, failing the old capture
+func TestSuperviseStream_Restart(t *testing.T) {
+	reader, writer := io.Pipe()
+	timeline := new_timeline(writer)
+	failure := errors.New("segmenter failed")
+	received := make(chan []byte)
+
+	attempts := 0
+	go supervise_stream(reader, timeline, func(stream *io.PipeReader) error {
+		attempts++
+		if attempts == 1 {
+			return failure
+		}
+		data := make([]byte, 4)
+		if _, err := io.ReadFull(stream, data); err != nil {
+			return err
+		}
+		received <- data
+		<-t.Context().Done()
+		return nil
+	})
+
+	// Capture writing to the failed stream sees the failure
+	if _, err := timeline.Write([]byte{1, 2, 3, 4}); !errors.Is(err, failure) {
+		t.Fatalf("Expected write to fail with %q, got: %v", failure, err)
+	}
+
+	// Next capture writes to the new stream
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := timeline.Write([]byte{5, 6, 7, 8}); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Stream was not restarted")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if data := <-received; !bytes.Equal(data, []byte{5, 6, 7, 8}) {
+		t.Errorf("Unexpected data on restarted stream: %v", data)
+	}
+}
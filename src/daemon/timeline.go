@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+	"dtrack/log"
+	"dtrack/state"
+
+	// Standard
+	"io"
+	"sync"
+	"time"
+)
+
+// Position in the daemon stream where an ffmpeg capture begins
+type capture_mark struct {
+	start   int64     // Stream byte where the capture begins
+	started time.Time // Wall-clock time when the capture began
+}
+
+// Recording location of a single audio segment
+type segment_location struct {
+	recording string    // Recording filename (no directory)
+	offset    int       // Seconds since the start of the recording
+	started   time.Time // Wall-clock time at the start of the segment
+}
+
+// Allowed difference between stream time and recording names
+// (ffmpeg names each file when it is opened, after the device starts).
+const recording_slack = 2 * time.Second
+
+// Writer that remembers which recording produced each byte of the stream
+type stream_timeline struct {
+	lock       sync.Mutex
+	writer     io.WriteCloser
+	written    int64
+	partial    []byte
+	marks      []capture_mark
+	recordings func() ([]ffmpeg.Recording, error)
+	current    ffmpeg.Recording
+	rotation   time.Time
+}
+
+// Wrap the writing end of the daemon stream
+func new_timeline(writer io.WriteCloser) *stream_timeline {
+	return &stream_timeline{writer: writer, recordings: ffmpeg.List_Recordings}
+}
+
+// Forward whole samples to the stream, counting each byte written
+// A trailing partial sample is held until the rest of it arrives.
+func (t *stream_timeline) Write(data []byte) (int, error) {
+	t.lock.Lock()
+	buffer := append(t.partial, data...)
+	whole := len(buffer) - len(buffer)%ffmpeg.SampleBytes
+	t.partial = append([]byte{}, buffer[whole:]...)
+	writer := t.writer
+	t.lock.Unlock()
+
+	n, err := writer.Write(buffer[:whole])
+	t.lock.Lock()
+	if writer == t.writer {
+		t.written += int64(n)
+	}
+	t.lock.Unlock()
+	return len(data), err
+}
+
+// Close the underlying stream
+func (t *stream_timeline) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.writer.Close()
+}
+
+// Note that all following bytes belong to a new ffmpeg capture
+func (t *stream_timeline) begin(started time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	// Partial sample from an ffmpeg that stopped mid-write would shift
+	// every following sample
+	if len(t.partial) > 0 {
+		log.Debug("Dropped %d bytes of partial audio sample", len(t.partial))
+		t.partial = nil
+	}
+	t.marks = append(t.marks, capture_mark{
+		start:   t.written,
+		started: started,
+	})
+}
+
+// Replace the daemon stream after the segmenter restarts
+// Positions in the new stream start from zero (and from now).
+func (t *stream_timeline) connect(writer io.WriteCloser) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.writer.Close()
+	t.writer = writer
+	t.written = 0
+	t.partial = nil
+	t.marks = []capture_mark{{start: 0, started: time.Now()}}
+}
+
+// Find the recording (and offset) that holds a stream byte position
+// Positions must be located in order; older captures are forgotten.
+func (t *stream_timeline) locate(position int64) segment_location {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Latest mark at (or before) position
+	found := -1
+	for i, mark := range t.marks {
+		if mark.start > position {
+			break
+		}
+		found = i
+	}
+	if found < 0 {
+		return segment_location{started: time.Now()}
+	}
+	t.marks = t.marks[found:]
+
+	mark := t.marks[0]
+	seconds := (position - mark.start) / int64(ffmpeg.Bytes_Per_Second())
+	location := segment_location{
+		started: mark.started.Add(time.Duration(seconds) * time.Second),
+	}
+	if recording := t.recording_at(location.started); recording.Name != "" {
+		location.recording = recording.Name
+		location.offset = max(0, int(location.started.Sub(recording.Start).Seconds()))
+	}
+	return location
+}
+
+// Recording that was being written at a point in time; lock must be held
+// Recordings are only listed again once the current one has rotated.
+func (t *stream_timeline) recording_at(when time.Time) ffmpeg.Recording {
+	if t.current.Name != "" && !t.current.Start.After(when.Add(recording_slack)) && when.Before(t.rotation) {
+		return t.current
+	}
+	recordings, err := t.recordings()
+	if err != nil {
+		return ffmpeg.Recording{}
+	}
+	t.current = ffmpeg.Recording{}
+	for _, recording := range recordings {
+		if recording.Start.After(when) {
+			// First recording of a capture may be named a little late
+			uncovered := t.current.Name == "" || !t.current.End.After(when)
+			if uncovered && !recording.Start.After(when.Add(recording_slack)) {
+				t.current = recording
+			}
+			break
+		}
+		t.current = recording
+	}
+
+	// Newest recording may end early, at the next wall-clock rotation
+	t.rotation = t.current.End
+	if duration, err := ffmpeg.Parse_Duration(state.Runtime.Record_Duration); err == nil {
+		if rotation := ffmpeg.Next_Rotation(t.current.Start, duration); rotation.Before(t.rotation) {
+			t.rotation = rotation
+		}
+	}
+	return t.current
+}
@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+	"dtrack/state"
+
+	// Standard
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// Buffer that satisfies io.WriteCloser
+type nop_closer struct {
+	bytes.Buffer
+}
+
+func (nop_closer) Close() error { return nil }
+
+// Listing of recordings made by a single continuous capture
+func fake_recordings(names ...string) func() ([]ffmpeg.Recording, error) {
+	return func() ([]ffmpeg.Recording, error) {
+		recordings := []ffmpeg.Recording{}
+		for _, name := range names {
+			start, _ := time.ParseInLocation(ffmpeg.SaveName, name, time.Local)
+			recordings = append(recordings, ffmpeg.Recording{
+				Name: name, Start: start, End: start.Add(10 * time.Minute)})
+		}
+		for i := 0; i+1 < len(recordings); i++ {
+			recordings[i].End = recordings[i+1].Start
+		}
+		return recordings, nil
+	}
+}
+
+// Segments must map back to the recording (and offset) that produced them
+func TestTimeline_Locate(t *testing.T) {
+	state.Runtime.Record_Duration = "00:10:00"
+	timeline := new_timeline(&nop_closer{})
+	timeline.recordings = fake_recordings(
+		"2025-06-01_220317.mkv", "2025-06-01_221000.mkv", "2025-06-01_221531.mkv")
+	first := time.Date(2025, 6, 1, 22, 3, 16, 600000000, time.Local)
+	restart := time.Date(2025, 6, 1, 22, 15, 30, 0, time.Local)
+	second_of_audio := make([]byte, ffmpeg.Bytes_Per_Second())
+
+	// First capture: rotates (without stopping) at 22:10:00
+	timeline.begin(first)
+	for i := 0; i < 500; i++ {
+		timeline.Write(second_of_audio)
+	}
+	// Second capture (after ffmpeg restart)
+	timeline.begin(restart)
+	for i := 0; i < 2; i++ {
+		timeline.Write(second_of_audio)
+	}
+
+	tests := []struct {
+		segment   int64
+		recording string
+		offset    int
+		started   time.Time
+	}{
+		{0, "2025-06-01_220317.mkv", 0, first},
+		{403, "2025-06-01_220317.mkv", 402, first.Add(403 * time.Second)},
+		{404, "2025-06-01_221000.mkv", 0, first.Add(404 * time.Second)},
+		{499, "2025-06-01_221000.mkv", 95, first.Add(499 * time.Second)},
+		{501, "2025-06-01_221531.mkv", 0, restart.Add(1 * time.Second)},
+	}
+	for _, tt := range tests {
+		actual := timeline.locate(tt.segment * int64(ffmpeg.Bytes_Per_Second()))
+		if actual.recording != tt.recording || actual.offset != tt.offset {
+			t.Errorf("Segment %d: expected %s+%d, got %s+%d",
+				tt.segment, tt.recording, tt.offset, actual.recording, actual.offset)
+		}
+		if !actual.started.Equal(tt.started) {
+			t.Errorf("Segment %d: expected start %s, got %s",
+				tt.segment, tt.started, actual.started)
+		}
+	}
+}
+
+// Segments read through the converter carry their recording location
+func TestStreamToSegment_Location(t *testing.T) {
+	state.Runtime.Record_Duration = "00:10:00"
+	reader, writer := io.Pipe()
+	timeline := new_timeline(writer)
+	timeline.recordings = fake_recordings("2025-06-01_220958.mkv", "2025-06-01_221000.mkv")
+	segments := make(chan audio_segment)
+	go stream_to_segment(reader, timeline, segments)
+
+	started := time.Date(2025, 6, 1, 22, 9, 58, 0, time.Local)
+	go func() {
+		timeline.begin(started)
+		timeline.Write(make([]byte, ffmpeg.Bytes_Per_Second()*3))
+	}()
+
+	expected := []string{"2025-06-01_220958.mkv+0", "2025-06-01_220958.mkv+1", "2025-06-01_221000.mkv+0"}
+	for i, want := range expected {
+		segment := <-segments
+		got := fmt.Sprintf("%s+%d", segment.location.recording, segment.location.offset)
+		if segment.count != uint(i) || got != want {
+			t.Errorf("Segment %d: expected %s, got #%d %s", i, want, segment.count, got)
+		}
+	}
+}
+
+// Only recordings the segment muxer has moved on from are finished
+func TestSegmentTracker_NewlyFinished(t *testing.T) {
+	started := time.Date(2025, 6, 1, 22, 3, 16, 600000000, time.Local)
+	tracker := new_segment_tracker(started)
+	recordings, _ := fake_recordings(
+		"2025-06-01_215000.mkv", "2025-06-01_220316.mkv", "2025-06-01_221000.mkv")()
+
+	names := func(recordings []ffmpeg.Recording) string {
+		found := ""
+		for _, recording := range recordings {
+			found += recording.Name + " "
+		}
+		return found
+	}
+	if got := names(tracker.newly_finished(recordings, false)); got != "2025-06-01_220316.mkv " {
+		t.Errorf("Expected first recording of this capture, got: %s", got)
+	}
+	if got := names(tracker.newly_finished(recordings, false)); got != "" {
+		t.Errorf("Expected nothing new, got: %s", got)
+	}
+	if got := names(tracker.newly_finished(recordings, true)); got != "2025-06-01_221000.mkv " {
+		t.Errorf("Expected newest recording once ffmpeg exited, got: %s", got)
+	}
+}
+
+// Samples stay aligned across ffmpeg restarts, even if one stops mid-sample
+func TestStreamToSegment_Alignment(t *testing.T) {
+	state.Runtime.Record_Duration = "00:10:00"
+	reader, writer := io.Pipe()
+	timeline := new_timeline(writer)
+	timeline.recordings = fake_recordings()
+	segments := make(chan audio_segment)
+	go stream_to_segment(reader, timeline, segments)
+
+	// Every sample is 0x0102 (little-endian), written in odd-sized pieces
+	sample := []byte{0x02, 0x01}
+	capture := func(samples int, stray bool) {
+		audio := bytes.Repeat(sample, samples)
+		if stray {
+			audio = append(audio, sample[0])
+		}
+		timeline.begin(time.Now())
+		for len(audio) > 0 {
+			size := min(1001, len(audio))
+			timeline.Write(audio[:size])
+			audio = audio[size:]
+		}
+	}
+	go func() {
+		// Three "files": the first two stop mid-sample
+		capture(ffmpeg.Sample_Rate()/2, true)
+		capture(ffmpeg.Sample_Rate(), true)
+		capture(ffmpeg.Sample_Rate()*3/2, false)
+	}()
+
+	for i := 0; i < 3; i++ {
+		segment := <-segments
+		for j := 0; j < len(segment.data); j += ffmpeg.SampleBytes {
+			if !bytes.Equal(segment.data[j:j+ffmpeg.SampleBytes], sample) {
+				t.Fatalf("Segment %d: sample %d misaligned: % x",
+					i, j/ffmpeg.SampleBytes, segment.data[j:j+ffmpeg.SampleBytes])
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// ##
+// DTrack Package: Detection Decisions
+//
+// Turns per-window model probabilities into detections, smoothing over time so
+// a single loud window does not count as a disturbance.
+// ##
+package decision
+
+import (
+	// DTrack
+	"dtrack/model"
+	"dtrack/state"
+)
+
+// Class that never counts as a detection
+const Empty = "empty"
+
+// How windows are combined before deciding; zero values disable each step
+type Smoothing struct {
+	Required int     // Windows (of the last Window) above trust to open a class
+	Window   int     // Windows considered by Required
+	Average  int     // Windows of probabilities averaged together
+	Release  float64 // Open classes close at or below this confidence (0 = trust)
+}
+
+// Smoothing settings from a configuration
+func Configured(cfg *state.Application_Configuration) Smoothing {
+	return Smoothing{
+		Required: cfg.Record_Detect_Required,
+		Window:   cfg.Record_Detect_Window,
+		Average:  cfg.Record_Detect_Average,
+		Release:  cfg.Record_Detect_Release,
+	}
+}
+
+// Outcome of a single window
+type Decision struct {
+	Class         string             // Best class (open if Matched)
+	Confidence    float64            // Averaged confidence of Class
+	Probabilities map[string]float64 // Averaged probabilities of every class
+	Matched       bool               // Class is currently detected
+	Opened        bool               // Class was detected starting with this window
+	Open          map[string]bool    // Every class currently detected
+}
+
+// Decision state for one model over one continuous stream of windows
+type Smoother struct {
+	Settings Smoothing                  // May be changed between windows
+	trust    func(class string) float64 // Confidence required to open a class
+	history  []map[string]float64       // Latest probabilities, oldest first
+	above    map[string][]bool          // Latest windows above trust, per class
+	open     map[string]bool            // Classes currently detected
+}
+
+// Create a smoother; trust returns the confidence required for each class
+func New(settings Smoothing, trust func(class string) float64) *Smoother {
+	return &Smoother{
+		Settings: settings,
+		trust:    trust,
+		above:    make(map[string][]bool),
+		open:     make(map[string]bool),
+	}
+}
+
+// Add the probabilities of the next window and decide what it contains
+func (s *Smoother) Update(probabilities map[string]float64) Decision {
+	averaged := s.average(probabilities)
+	best, confidence := model.Best_Match(averaged)
+	decision := Decision{Class: best, Confidence: confidence, Probabilities: averaged}
+
+	// Track which classes were above trust in each window
+	classes := make(map[string]bool)
+	for class := range averaged {
+		classes[class] = true
+	}
+	for class := range s.above {
+		classes[class] = true
+	}
+	for class := range classes {
+		above := class == best && class != Empty && confidence > s.trust(class)
+		s.above[class] = keep_last(append(s.above[class], above), max(s.Settings.Window, 1))
+	}
+
+	// Open after enough windows above trust; close at or below release
+	// Without a release level, classes close as soon as they are not the best match.
+	opened := make(map[string]bool)
+	for class := range classes {
+		if s.open[class] {
+			release, held := s.release(class)
+			if averaged[class] <= release || (!held && class != best) {
+				delete(s.open, class)
+			}
+		} else if class != Empty && count(s.above[class]) >= max(s.Settings.Required, 1) {
+			s.open[class] = true
+			opened[class] = true
+		}
+	}
+
+	// Report the most likely open class
+	for class := range s.open {
+		if !decision.Matched || averaged[class] > decision.Confidence {
+			decision.Class = class
+			decision.Confidence = averaged[class]
+			decision.Matched = true
+		}
+	}
+	decision.Opened = opened[decision.Class]
+	decision.Open = make(map[string]bool, len(s.open))
+	for class := range s.open {
+		decision.Open[class] = true
+	}
+	return decision
+}
+
+// Confidence at (or below) which an open class closes
+// Returns true if a release level is used, holding the class open while it is not the best match.
+func (s *Smoother) release(class string) (float64, bool) {
+	trust := s.trust(class)
+	if s.Settings.Release <= 0 || s.Settings.Release > trust {
+		return trust, false
+	}
+	return s.Settings.Release, true
+}
+
+// Moving average of the latest Average windows (including this one)
+func (s *Smoother) average(probabilities map[string]float64) map[string]float64 {
+	s.history = keep_last(append(s.history, probabilities), max(s.Settings.Average, 1))
+	averaged := make(map[string]float64)
+	for _, window := range s.history {
+		for class, probability := range window {
+			averaged[class] += probability / float64(len(s.history))
+		}
+	}
+	return averaged
+}
+
+// Trim a slice to (at most) its last size entries
+func keep_last[T any](values []T, size int) []T {
+	if len(values) > size {
+		return append(values[:0:0], values[len(values)-size:]...)
+	}
+	return values
+}
+
+// Number of true values
+func count(values []bool) int {
+	total := 0
+	for _, value := range values {
+		if value {
+			total++
+		}
+	}
+	return total
+}
@@ -0,0 +1,123 @@
+package decision_test
+
+import (
+	// DTrack
+	"dtrack/decision"
+
+	// Standard
+	"testing"
+)
+
+// Same trust for every class
+func flat_trust(trust float64) func(string) float64 {
+	return func(string) float64 { return trust }
+}
+
+// Probabilities of a two-class (bark/empty) model
+func bark(p float64) map[string]float64 {
+	return map[string]float64{"bark": p, "empty": 1 - p}
+}
+
+// Feed a sequence of bark probabilities, returning Matched for each window
+func run_sequence(smoother *decision.Smoother, sequence []float64) []bool {
+	matched := make([]bool, len(sequence))
+	for i, p := range sequence {
+		matched[i] = smoother.Update(bark(p)).Matched
+	}
+	return matched
+}
+
+// Compare two sequences of decisions
+func check_sequence(t *testing.T, name string, expected []bool, actual []bool) {
+	t.Helper()
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("%s: window %d expected matched=%t, got %v", name, i, expected[i], actual)
+			return
+		}
+	}
+}
+
+// Default settings match any single window above trust
+func TestSmoother_Defaults(t *testing.T) {
+	smoother := decision.New(decision.Smoothing{}, flat_trust(0.5))
+	actual := run_sequence(smoother, []float64{0.9, 0.4, 0.51, 0.5})
+	check_sequence(t, "defaults", []bool{true, false, true, false}, actual)
+
+	// "empty" is never a detection
+	if smoother.Update(map[string]float64{"empty": 1}).Matched {
+		t.Error("Matched the empty class")
+	}
+}
+
+// Without a release level, a class above a low trust closes once it is not the best match
+func TestSmoother_LowTrust(t *testing.T) {
+	smoother := decision.New(decision.Smoothing{}, flat_trust(0.3))
+	actual := run_sequence(smoother, []float64{0.6, 0.4, 0.35, 0.6})
+	check_sequence(t, "low trust", []bool{true, false, false, true}, actual)
+
+	// With a release level, the class is held open while above it
+	smoother = decision.New(decision.Smoothing{Release: 0.2}, flat_trust(0.3))
+	actual = run_sequence(smoother, []float64{0.6, 0.4, 0.35, 0.2})
+	check_sequence(t, "low trust release", []bool{true, true, true, false}, actual)
+}
+
+// N-of-M: a class opens once Required of the last Window windows are above trust
+func TestSmoother_Required(t *testing.T) {
+	settings := decision.Smoothing{Required: 2, Window: 3}
+
+	smoother := decision.New(settings, flat_trust(0.5))
+	actual := run_sequence(smoother, []float64{0.9, 0.1, 0.9, 0.9})
+	check_sequence(t, "two of three", []bool{false, false, true, true}, actual)
+
+	// Isolated spikes never open
+	smoother = decision.New(settings, flat_trust(0.5))
+	actual = run_sequence(smoother, []float64{0.9, 0.1, 0.1, 0.9, 0.1, 0.1, 0.9})
+	check_sequence(t, "isolated spikes", []bool{false, false, false, false, false, false, false}, actual)
+}
+
+// Open classes stay open until they drop to the release threshold
+func TestSmoother_Release(t *testing.T) {
+	smoother := decision.New(decision.Smoothing{Release: 0.4}, flat_trust(0.7))
+	actual := run_sequence(smoother, []float64{0.6, 0.8, 0.5, 0.45, 0.4, 0.6, 0.71})
+	check_sequence(t, "release", []bool{false, true, true, true, false, false, true}, actual)
+
+	// Release above trust is ignored
+	smoother = decision.New(decision.Smoothing{Release: 0.9}, flat_trust(0.7))
+	actual = run_sequence(smoother, []float64{0.8, 0.75, 0.7})
+	check_sequence(t, "high release", []bool{true, true, false}, actual)
+}
+
+// Moving average smooths out a single loud window
+func TestSmoother_Average(t *testing.T) {
+	smoother := decision.New(decision.Smoothing{Average: 3}, flat_trust(0.5))
+	actual := run_sequence(smoother, []float64{0.0, 0.0, 1.0, 1.0, 1.0, 0.0, 0.0})
+	check_sequence(t, "average", []bool{false, false, false, true, true, true, false}, actual)
+
+	decision := smoother.Update(bark(0.9))
+	if decision.Probabilities["bark"] < 0.29 || decision.Probabilities["bark"] > 0.31 {
+		t.Errorf("Expected averaged probability 0.3, got %.4f", decision.Probabilities["bark"])
+	}
+}
+
+// Each class can require a different confidence
+func TestSmoother_ClassTrust(t *testing.T) {
+	trust := func(class string) float64 {
+		if class == "siren" {
+			return 0.9
+		}
+		return 0.5
+	}
+	smoother := decision.New(decision.Smoothing{}, trust)
+
+	if smoother.Update(map[string]float64{"siren": 0.8, "empty": 0.2}).Matched {
+		t.Error("Siren matched below its trust")
+	}
+	result := smoother.Update(map[string]float64{"bark": 0.6, "empty": 0.4})
+	if !result.Matched || result.Class != "bark" || !result.Opened {
+		t.Errorf("Expected newly opened bark, got %+v", result)
+	}
+	if result = smoother.Update(bark(0.7)); !result.Matched || result.Opened {
+		t.Errorf("Expected bark to remain open, got %+v", result)
+	}
+}
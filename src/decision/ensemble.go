@@ -0,0 +1,45 @@
+package decision
+
+import (
+	// DTrack
+	"dtrack/state"
+)
+
+// Combine the decisions of several models for the same window
+// Never matches unless every model of the ensemble decided this window.
+func Combine(ensemble state.Ensemble, decisions map[string]Decision, trust float64) Decision {
+	combined := Decision{
+		Class:         ensemble.Class,
+		Probabilities: make(map[string]float64),
+		Open:          make(map[string]bool),
+	}
+
+	// Count votes and average probabilities across models
+	votes := 0
+	for _, name := range ensemble.Models {
+		decision, ok := decisions[name]
+		if !ok {
+			return Decision{Class: ensemble.Class}
+		}
+		if decision.Open[ensemble.Class] {
+			votes++
+		}
+		for class, probability := range decision.Probabilities {
+			combined.Probabilities[class] += probability / float64(len(ensemble.Models))
+		}
+	}
+	combined.Confidence = combined.Probabilities[ensemble.Class]
+
+	switch ensemble.Rule {
+	case state.Rule_All:
+		combined.Matched = votes == len(ensemble.Models)
+	case state.Rule_Majority:
+		combined.Matched = votes*2 > len(ensemble.Models)
+	case state.Rule_Average:
+		combined.Matched = ensemble.Class != Empty && combined.Confidence > trust
+	}
+	if combined.Matched {
+		combined.Open[ensemble.Class] = true
+	}
+	return combined
+}
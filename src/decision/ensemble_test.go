@@ -0,0 +1,61 @@
+package decision_test
+
+import (
+	// DTrack
+	"dtrack/decision"
+	"dtrack/state"
+
+	// Standard
+	"testing"
+)
+
+// Decision of a single (smoothed) model
+func decided(p float64, open bool) decision.Decision {
+	return decision.Decision{
+		Probabilities: bark(p),
+		Open:          map[string]bool{"bark": open},
+	}
+}
+
+// Each rule combines the same decisions differently
+func TestCombine_Rules(t *testing.T) {
+	decisions := map[string]decision.Decision{
+		"a": decided(0.9, true),
+		"b": decided(0.7, true),
+		"c": decided(0.2, false),
+	}
+	tests := []struct {
+		rule     string
+		models   []string
+		expected bool
+	}{
+		{state.Rule_All, []string{"a", "b"}, true},
+		{state.Rule_All, []string{"a", "b", "c"}, false},
+		{state.Rule_Majority, []string{"a", "b", "c"}, true},
+		{state.Rule_Majority, []string{"a", "c"}, false},
+		{state.Rule_Average, []string{"a", "b", "c"}, true},
+		{state.Rule_Average, []string{"b", "c"}, false},
+	}
+	for _, tt := range tests {
+		ensemble := state.Ensemble{Name: "dogs", Class: "bark", Rule: tt.rule, Models: tt.models}
+		result := decision.Combine(ensemble, decisions, 0.5)
+		if result.Matched != tt.expected {
+			t.Errorf("%s %v: expected matched=%t (Conf: %.2f)", tt.rule, tt.models, tt.expected, result.Confidence)
+		}
+	}
+
+	// Probabilities are averaged across models
+	ensemble := state.Ensemble{Name: "dogs", Class: "bark", Rule: state.Rule_Average, Models: []string{"a", "c"}}
+	if result := decision.Combine(ensemble, decisions, 0.5); result.Confidence < 0.549 || result.Confidence > 0.551 {
+		t.Errorf("Expected mean confidence 0.55, got %.4f", result.Confidence)
+	}
+}
+
+// Models that missed a window never vote for it
+func TestCombine_Missing(t *testing.T) {
+	decisions := map[string]decision.Decision{"a": decided(0.9, true)}
+	ensemble := state.Ensemble{Name: "dogs", Class: "bark", Rule: state.Rule_Majority, Models: []string{"a", "b"}}
+	if decision.Combine(ensemble, decisions, 0.5).Matched {
+		t.Error("Matched without a decision from every model")
+	}
+}
@@ -0,0 +1,180 @@
+// ##
+// DTrack Package: Event Store
+//
+// Append-only (JSON Lines) record of everything the daemon observed.
+// ##
+package events
+
+import (
+	// DTrack
+	"dtrack/log"
+	"dtrack/state"
+
+	// Standard
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Event files, relative to <workspace>/events/
+const (
+	Detections = "detections.jsonl"
+	Incidents  = "incidents.jsonl"
+	Outages    = "outages.jsonl"
+	Gaps       = "gaps.jsonl"
+)
+
+// Prevent interleaved writes from concurrent scanners
+var write_lock sync.Mutex
+
+// Single model match for one inspected window
+type Detection struct {
+	Time          time.Time          `json:"time"`
+	Model         string             `json:"model"`
+	Class         string             `json:"class"`
+	Confidence    float64            `json:"confidence"`
+	Probabilities map[string]float64 `json:"probabilities"`
+	Recording     string             `json:"recording"`
+	Offset        int                `json:"offset"`
+}
+
+// Consecutive matches of one model/class, merged into a single disturbance
+type Incident struct {
+	Model     string    `json:"model"`
+	Class     string    `json:"class"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Duration  float64   `json:"duration"`
+	Peak      float64   `json:"peak"`
+	Mean      float64   `json:"mean"`
+	Matches   int       `json:"matches"`
+	Recording string    `json:"recording"`
+	Offset    int       `json:"offset"`
+}
+
+// Time when nothing was being recorded (capture failed and was restarting)
+type Outage struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration"`
+	Failures int       `json:"failures"`
+	Reason   string    `json:"reason"`
+}
+
+// Windows one scanner never inspected (it was too far behind, or disabled)
+type Gap struct {
+	Model     string    `json:"model"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Duration  float64   `json:"duration"`
+	Windows   int       `json:"windows"`
+	Recording string    `json:"recording"`
+	Offset    int       `json:"offset"`
+}
+
+// Returns the directory holding all event files
+func Directory() string {
+	return filepath.Join(state.Runtime.Workspace, "events")
+}
+
+// Append one JSON-encoded record to an event file
+func Append(name string, record any) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	write_lock.Lock()
+	defer write_lock.Unlock()
+
+	// Verify output directory exists
+	if err := os.MkdirAll(Directory(), 0755); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(
+		filepath.Join(Directory(), name),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	// Single write per record; sync to survive power loss
+	if _, err := fh.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return fh.Sync()
+}
+
+// Save a detection to the event store
+func Record_Detection(detection Detection) error {
+	return Append(Detections, detection)
+}
+
+// Save a finished incident to the event store
+func Record_Incident(incident Incident) error {
+	return Append(Incidents, incident)
+}
+
+// Save a finished outage to the event store
+func Record_Outage(outage Outage) error {
+	return Append(Outages, outage)
+}
+
+// Save a finished inspection gap to the event store
+func Record_Gap(gap Gap) error {
+	return Append(Gaps, gap)
+}
+
+// Read every record from an event file; a missing file has no records
+// Undecodable lines (e.g. torn by a power loss) are skipped with a warning.
+func Read[T any](name string) ([]T, error) {
+	records := []T{}
+	fh, err := os.Open(filepath.Join(Directory(), name))
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		// Skip blank lines
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record T
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Warn("Skipping %s line %d: %s", name, line, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Load all recorded detections
+func Load_Detections() ([]Detection, error) {
+	return Read[Detection](Detections)
+}
+
+// Load all recorded incidents
+func Load_Incidents() ([]Incident, error) {
+	return Read[Incident](Incidents)
+}
+
+// Load all recorded outages
+func Load_Outages() ([]Outage, error) {
+	return Read[Outage](Outages)
+}
+
+// Load all recorded inspection gaps
+func Load_Gaps() ([]Gap, error) {
+	return Read[Gap](Gaps)
+}
@@ -0,0 +1,125 @@
+package events_test
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/state"
+
+	// Standard
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Point the event store at a throw-away workspace
+func setupWorkspace(t *testing.T) {
+	state.Runtime = state.Application_Configuration{
+		Workspace: t.TempDir(),
+	}
+}
+
+// Detections must survive a round trip through the event store
+func TestRecord_Detection(t *testing.T) {
+	setupWorkspace(t)
+
+	when := time.Date(2025, 6, 1, 22, 15, 3, 0, time.UTC)
+	expected := events.Detection{
+		Time:          when,
+		Model:         "dog",
+		Class:         "big_dog",
+		Confidence:    0.91,
+		Probabilities: map[string]float64{"big_dog": 0.91, "empty": 0.09},
+		Recording:     "2025-06-01_221000.mkv",
+		Offset:        303,
+	}
+	for i := 0; i < 3; i++ {
+		if err := events.Record_Detection(expected); err != nil {
+			t.Fatalf("Record_Detection failed: %v", err)
+		}
+	}
+
+	actual, err := events.Load_Detections()
+	if err != nil {
+		t.Fatalf("Load_Detections failed: %v", err)
+	}
+	if len(actual) != 3 {
+		t.Fatalf("Expected 3 detections, got %d", len(actual))
+	}
+	got := actual[2]
+	if !got.Time.Equal(when) || got.Model != "dog" || got.Class != "big_dog" {
+		t.Errorf("Unexpected detection: %+v", got)
+	}
+	if got.Probabilities["empty"] != 0.09 {
+		t.Errorf("Probabilities not preserved: %v", got.Probabilities)
+	}
+	if got.Recording != expected.Recording || got.Offset != expected.Offset {
+		t.Errorf("Recording location not preserved: %s @ %d", got.Recording, got.Offset)
+	}
+}
+
+// A workspace without an event file has no detections
+func TestLoad_Detections_Missing(t *testing.T) {
+	setupWorkspace(t)
+
+	actual, err := events.Load_Detections()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(actual) != 0 {
+		t.Errorf("Expected no detections, got %d", len(actual))
+	}
+}
+
+// Corrupt records (e.g. a torn final line) are skipped; the rest still load
+func TestLoad_Detections_Corrupt(t *testing.T) {
+	setupWorkspace(t)
+
+	for i := 0; i < 2; i++ {
+		if err := events.Record_Detection(events.Detection{Model: "dog", Offset: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(events.Directory(), events.Detections)
+	fh, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.WriteString(`{"time":"2025-06-01T22:15:03Z","model":"d`)
+	fh.Close()
+
+	actual, err := events.Load_Detections()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(actual) != 2 || actual[1].Offset != 1 {
+		t.Errorf("Expected the 2 complete detections, got %+v", actual)
+	}
+}
+
+// Date flags convert to an inclusive range of whole days
+func TestParse_Range(t *testing.T) {
+	from, to, err := events.Parse_Range("2025-06-01", "2025-06-03")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if from.Format(events.DateFormat) != "2025-06-01" || to.Format(events.DateFormat) != "2025-06-04" {
+		t.Errorf("Unexpected range: %s to %s", from, to)
+	}
+
+	// Times are exact
+	from, to, err = events.Parse_Range("2025-06-01 22:00:00", "2025-06-01 22:05")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if to.Sub(from) != 5*time.Minute {
+		t.Errorf("Expected 5 minute range, got %s", to.Sub(from))
+	}
+
+	if _, _, err := events.Parse_Range("2025-06-03", "2025-06-01"); err == nil {
+		t.Error("Expected error for reversed range")
+	}
+	if _, _, err := events.Parse_Range("June 1st", ""); err == nil {
+		t.Error("Expected error for invalid date")
+	}
+}
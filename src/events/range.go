@@ -0,0 +1,59 @@
+package events
+
+import (
+	// Standard
+	"fmt"
+	"time"
+)
+
+// Date format used for whole days (e.g. -s/-u flags and report headings)
+const DateFormat = "2006-01-02"
+
+// Accepted formats for a single moment; date-only values mean a whole day
+var Moment_Formats = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	DateFormat,
+}
+
+// Convert -s/-u values into a [from, to) time range
+// A date-only "until" includes that entire day. Defaults to the last 7 days.
+func Parse_Range(since string, until string) (time.Time, time.Time, error) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local)
+	if until != "" {
+		parsed, layout, err := parse_moment(until)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = parsed
+		if layout == DateFormat {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	from := to.AddDate(0, 0, -7)
+	if since != "" {
+		parsed, _, err := parse_moment(since)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s is after %s", since, until)
+	}
+	return from, to, nil
+}
+
+// Parse a local date or date/time, returning the matched layout
+func parse_moment(value string) (time.Time, string, error) {
+	for _, layout := range Moment_Formats {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, layout, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("unrecognized date: %s (expected YYYY-MM-DD [HH:MM:SS])", value)
+}
@@ -0,0 +1,310 @@
+// ##
+// DTrack Package: Evidence Export
+//
+// Clips incidents (or a time range) out of saved recordings and bundles them
+// with a manifest of detections and SHA-256 hashes of every file.
+// ##
+package export
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/ffmpeg"
+	"dtrack/ledger"
+	"dtrack/log"
+	"dtrack/state"
+
+	// Standard
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Name of the manifest file inside every bundle
+const ManifestName = "manifest.json"
+
+// Name of the sha256sum-compatible checksum file inside every bundle
+const ChecksumName = "SHA256SUMS"
+
+// Complete description of an export bundle
+type Manifest struct {
+	Created   time.Time   `json:"created"`
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	Pre_Roll  int         `json:"pre_roll"`
+	Post_Roll int         `json:"post_roll"`
+	Entries   []Entry     `json:"entries"`
+	Files     []File_Hash `json:"files"`
+}
+
+// One exported span of time (usually a single incident)
+type Entry struct {
+	Incident   *events.Incident   `json:"incident,omitempty"`
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	Detections []events.Detection `json:"detections"`
+	Clips      []Clip             `json:"clips"`
+}
+
+// Part of a single recording, copied into the bundle
+type Clip struct {
+	File          string    `json:"file"`
+	Source        string    `json:"source"`
+	Source_SHA256 string    `json:"source_sha256"`
+	Offset        float64   `json:"offset"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+}
+
+// Hash of a single file inside the bundle
+type File_Hash struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// Primary post-bootstrap entry point
+// Export every incident in a range (or the entire range) to a bundle
+func Run(since string, until string, output string, whole_range bool) {
+	from, to, err := events.Parse_Range(since, until)
+	if err != nil {
+		log.Die("Invalid date range: %s", err)
+	}
+	recordings, err := ffmpeg.List_Recordings()
+	if err != nil {
+		log.Die("Unable to list recordings: %s", err)
+	}
+	detections, err := events.Load_Detections()
+	if err != nil {
+		log.Die("Unable to read detections: %s", err)
+	}
+
+	// Select spans of time to export
+	manifest := Manifest{
+		Created:   time.Now(),
+		From:      from,
+		To:        to,
+		Pre_Roll:  state.Runtime.Export_Pre_Roll,
+		Post_Roll: state.Runtime.Export_Post_Roll,
+	}
+	if whole_range {
+		manifest.Entries = []Entry{{Start: from, End: to}}
+	} else {
+		incidents, err := events.Load_Incidents()
+		if err != nil {
+			log.Die("Unable to read incidents: %s", err)
+		}
+		manifest.Entries = incident_entries(incidents, from, to)
+	}
+	if len(manifest.Entries) == 0 {
+		log.Die("No incidents found between %s and %s", from, to)
+	}
+
+	// Output to directory, or to a temporary directory that becomes a zip
+	zip_path := ""
+	if output == "" {
+		output = filepath.Join(state.Runtime.Workspace, "exports",
+			manifest.Created.Format("2006-01-02_150405"))
+	}
+	if strings.HasSuffix(output, ".zip") {
+		zip_path = output
+		if output, err = os.MkdirTemp("", "dtrack_*"); err != nil {
+			log.Die("Failed to make temporary directory: %s", err)
+		}
+		defer os.RemoveAll(output)
+	}
+	if err := os.MkdirAll(filepath.Join(output, "clips"), 0755); err != nil {
+		log.Die("Failed to make output directory: %s", output)
+	}
+
+	// Cut clips from recordings
+	source_hashes := make(map[string]string)
+	for i := range manifest.Entries {
+		entry := &manifest.Entries[i]
+		entry.Detections = detections_between(detections, entry.Start, entry.End)
+		entry.Clips = Plan_Clips(recordings, entry.Start, entry.End)
+		if len(entry.Clips) == 0 {
+			log.Warn("No recordings found for %s to %s", entry.Start, entry.End)
+		}
+		for j := range entry.Clips {
+			clip := &entry.Clips[j]
+			clip.File = fmt.Sprintf("clips/%03d_%s_%d.mkv",
+				i+1, strings.TrimSuffix(clip.Source, ".mkv"), int(clip.Offset))
+			source := filepath.Join(ffmpeg.Recordings_Directory(), clip.Source)
+			if _, ok := source_hashes[source]; !ok {
+				if source_hashes[source], _, err = ledger.Hash_File(source); err != nil {
+					log.Die("Unable to hash %s: %s", source, err)
+				}
+			}
+			clip.Source_SHA256 = source_hashes[source]
+
+			log.Debug("Exporting %s from %s @%.0fs", clip.File, clip.Source, clip.Offset)
+			args := ffmpeg.Clip_Arguments(source,
+				time.Duration(clip.Offset*float64(time.Second)),
+				clip.End.Sub(clip.Start), filepath.Join(output, clip.File))
+			if err := ffmpeg.Run(args); err != nil {
+				log.Die("Failed to export %s: %s", clip.File, err)
+			}
+		}
+	}
+
+	if err := Write_Manifest(output, &manifest); err != nil {
+		log.Die("Failed to write manifest: %s", err)
+	}
+	if zip_path != "" {
+		if err := zip_directory(output, zip_path); err != nil {
+			log.Die("Failed to write %s: %s", zip_path, err)
+		}
+		output = zip_path
+	}
+	log.Info("Exported %d entries to %s", len(manifest.Entries), output)
+}
+
+// Build one entry for each incident starting within [from, to)
+func incident_entries(incidents []events.Incident, from time.Time, to time.Time) []Entry {
+	pre_roll := time.Duration(state.Runtime.Export_Pre_Roll) * time.Second
+	post_roll := time.Duration(state.Runtime.Export_Post_Roll) * time.Second
+	entries := []Entry{}
+	for _, incident := range incidents {
+		if incident.Start.Before(from) || !incident.Start.Before(to) {
+			continue
+		}
+		entries = append(entries, Entry{
+			Incident: &incident,
+			Start:    incident.Start.Add(-pre_roll),
+			End:      incident.End.Add(post_roll),
+		})
+	}
+	return entries
+}
+
+// Detections (matched windows) that begin within [start, end)
+func detections_between(detections []events.Detection, start time.Time, end time.Time) []events.Detection {
+	found := []events.Detection{}
+	for _, detection := range detections {
+		if !detection.Time.Before(start) && detection.Time.Before(end) {
+			found = append(found, detection)
+		}
+	}
+	return found
+}
+
+// Determine which parts of which recordings cover [start, end)
+// Returns one clip per overlapping recording.
+func Plan_Clips(recordings []ffmpeg.Recording, start time.Time, end time.Time) []Clip {
+	clips := []Clip{}
+	for _, recording := range recordings {
+		if !recording.End.After(start) || !recording.Start.Before(end) {
+			continue
+		}
+		clip_start, clip_end := start, end
+		if clip_start.Before(recording.Start) {
+			clip_start = recording.Start
+		}
+		if clip_end.After(recording.End) {
+			clip_end = recording.End
+		}
+		clips = append(clips, Clip{
+			Source: recording.Name,
+			Offset: clip_start.Sub(recording.Start).Seconds(),
+			Start:  clip_start,
+			End:    clip_end,
+		})
+	}
+	return clips
+}
+
+// Hash every file in a bundle, then write the manifest and checksum file
+func Write_Manifest(bundle string, manifest *Manifest) error {
+	manifest.Files = []File_Hash{}
+	err := filepath.WalkDir(bundle, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(bundle, path)
+		if err != nil {
+			return err
+		}
+		if name == ManifestName || name == ChecksumName {
+			return nil
+		}
+		sum, size, err := ledger.Hash_File(path)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, File_Hash{
+			Name:   filepath.ToSlash(name),
+			SHA256: sum,
+			Bytes:  size,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(bundle, ManifestName), data, 0644); err != nil {
+		return err
+	}
+
+	// Checksum file also covers the manifest (sha256sum -c SHA256SUMS)
+	sum, size, err := ledger.Hash_File(filepath.Join(bundle, ManifestName))
+	if err != nil {
+		return err
+	}
+	hashes := append(manifest.Files, File_Hash{Name: ManifestName, SHA256: sum, Bytes: size})
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].Name < hashes[j].Name })
+	var sums strings.Builder
+	for _, hash := range hashes {
+		fmt.Fprintf(&sums, "%s  %s\n", hash.SHA256, hash.Name)
+	}
+	return os.WriteFile(filepath.Join(bundle, ChecksumName), []byte(sums.String()), 0644)
+}
+
+// Store every file in a directory inside a zip archive
+func zip_directory(directory string, zip_path string) error {
+	if err := os.MkdirAll(filepath.Dir(zip_path), 0755); err != nil {
+		return err
+	}
+	fh, err := os.Create(zip_path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	archive := zip.NewWriter(fh)
+	err = filepath.WalkDir(directory, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		writer, err := archive.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		source, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		_, err = io.Copy(writer, source)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
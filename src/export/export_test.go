@@ -0,0 +1,92 @@
+package export_test
+
+import (
+	// DTrack
+	"dtrack/export"
+	"dtrack/ffmpeg"
+	"dtrack/ledger"
+
+	// Standard
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Spans are split across every recording they overlap
+func TestPlan_Clips(t *testing.T) {
+	first := time.Date(2025, 6, 1, 22, 0, 0, 0, time.Local)
+	recordings := []ffmpeg.Recording{
+		{Name: "a.mkv", Start: first, End: first.Add(10 * time.Minute)},
+		{Name: "b.mkv", Start: first.Add(10 * time.Minute), End: first.Add(20 * time.Minute)},
+		{Name: "c.mkv", Start: first.Add(20 * time.Minute), End: first.Add(30 * time.Minute)},
+	}
+
+	// Entirely inside a single recording
+	clips := export.Plan_Clips(recordings, first.Add(90*time.Second), first.Add(2*time.Minute))
+	if len(clips) != 1 || clips[0].Source != "a.mkv" || clips[0].Offset != 90 {
+		t.Errorf("Unexpected single clip: %+v", clips)
+	}
+
+	// Crossing a recording boundary
+	clips = export.Plan_Clips(recordings, first.Add(9*time.Minute), first.Add(11*time.Minute))
+	if len(clips) != 2 {
+		t.Fatalf("Expected 2 clips, got %+v", clips)
+	}
+	if clips[0].Source != "a.mkv" || clips[0].Offset != 540 || clips[0].End.Sub(clips[0].Start) != time.Minute {
+		t.Errorf("Unexpected first clip: %+v", clips[0])
+	}
+	if clips[1].Source != "b.mkv" || clips[1].Offset != 0 || clips[1].End.Sub(clips[1].Start) != time.Minute {
+		t.Errorf("Unexpected second clip: %+v", clips[1])
+	}
+
+	// Nothing recorded
+	if clips := export.Plan_Clips(recordings, first.Add(-time.Hour), first); len(clips) != 0 {
+		t.Errorf("Expected no clips, got %+v", clips)
+	}
+}
+
+// Manifest and checksum file cover every file in the bundle
+func TestWrite_Manifest(t *testing.T) {
+	bundle := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(bundle, "clips"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bundle, "clips", "001.mkv"), []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := export.Manifest{Pre_Roll: 5, Post_Roll: 5}
+	if err := export.Write_Manifest(bundle, &manifest); err != nil {
+		t.Fatalf("Write_Manifest failed: %v", err)
+	}
+
+	// sha256("abc")
+	const abc = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if len(manifest.Files) != 1 || manifest.Files[0].Name != "clips/001.mkv" || manifest.Files[0].SHA256 != abc {
+		t.Errorf("Unexpected file hashes: %+v", manifest.Files)
+	}
+
+	// Manifest is valid JSON
+	data, err := os.ReadFile(filepath.Join(bundle, export.ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved export.Manifest
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Pre_Roll != 5 {
+		t.Errorf("Manifest not saved correctly: %v", err)
+	}
+
+	// Checksums cover clips and the manifest itself
+	sums, err := os.ReadFile(filepath.Join(bundle, export.ChecksumName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest_sum, _, _ := ledger.Hash_File(filepath.Join(bundle, export.ManifestName))
+	if !strings.Contains(string(sums), abc+"  clips/001.mkv\n") ||
+		!strings.Contains(string(sums), manifest_sum+"  "+export.ManifestName+"\n") {
+		t.Errorf("Unexpected checksum file:\n%s", sums)
+	}
+}
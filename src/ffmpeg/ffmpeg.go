@@ -7,24 +7,54 @@ import (
 
 	// Standard
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
 // One-second piece of audio from pcm_s16le:
 //    Bytes Per Second   = Sample Rate * Channels * (Bits Per Sample / 8)
-//    96000              = -ac 48000   * -c 1     * (16/8)
-const SampleRate     int = 48000
-const BytesPerSecond int = SampleRate * 2 // * 1 * (2)
+//    96000              = -ar 48000   * -ac 1    * (16/8)
+const DefaultSampleRate int = 48000
+const SampleBytes       int = 2 // Channels * (Bits Per Sample / 8)
+
+// Configured audio sample rate (Hz)
+func Sample_Rate() int {
+	if state.Runtime.Record_Audio_Rate == 0 {
+		return DefaultSampleRate
+	}
+	return state.Runtime.Record_Audio_Rate
+}
+
+// Bytes in one second of audio, at the configured sample rate
+func Bytes_Per_Second() int {
+	return Sample_Rate() * SampleBytes
+}
+
+// Output options for single-channel audio at the configured sample rate
+// With select_channel, one input channel is used instead of a mix of all.
+func audio_format(select_channel bool) []string {
+	args := []string{"-ar", strconv.Itoa(Sample_Rate()), "-ac", "1"}
+	if channel := state.Runtime.Record_Audio_Channel; select_channel && channel > 0 {
+		args = append(args, "-af", fmt.Sprintf("pan=mono|c0=c%d", channel-1))
+	}
+	return args
+}
 
 // MKV Filename:  YYYY-MM-DD_HHmmss
 const SaveName = "2006-01-02_150405.mkv"
 
+// MKV Filename, as written by the segment muxer (strftime form of SaveName)
+const SegmentName = "%Y-%m-%d_%H%M%S.mkv"
+
 // Run ffmpeg command, returning stdout to IO stream
-func ReadStdin(arguments []string, stdout *io.PipeWriter, endStream bool) {
+func ReadStdin(arguments []string, stdout io.WriteCloser, endStream bool) {
 	if endStream {
 		defer stdout.Close()
 	}
@@ -48,6 +78,59 @@ func ReadStdin(arguments []string, stdout *io.PipeWriter, endStream bool) {
 	}
 }
 
+// Run ffmpeg command until it exits, returning stdout to IO stream
+// Closing stop asks ffmpeg to finish cleanly (as if Ctrl+C was pressed).
+func Capture(arguments []string, stdout io.Writer, stop <-chan struct{}) error {
+	ffmpeg := exec.Command("ffmpeg", arguments...)
+	ffmpeg.Stderr = os.Stderr
+	ffmpeg.Stdout = stdout
+
+	// Use separate process group to avoid SIGTERM collisions
+	ffmpeg.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	if err := ffmpeg.Start(); err != nil {
+		return err
+	}
+	finished := make(chan error, 1)
+	go func() { finished <- ffmpeg.Wait() }()
+
+	select {
+	case err := <-finished:
+		return err
+	case <-stop:
+		// ffmpeg finalizes every output on SIGINT
+		ffmpeg.Process.Signal(os.Interrupt)
+		return <-finished
+	}
+}
+
+// Run ffmpeg command to completion, discarding stdout
+func Run(arguments []string) error {
+	ffmpeg := exec.Command("ffmpeg", arguments...)
+	ffmpeg.Stderr = os.Stderr
+
+	// Use separate process group to avoid SIGTERM collisions
+	ffmpeg.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	return ffmpeg.Run()
+}
+
+// Convert an ffmpeg duration ("HH:MM:SS[.m]", "MM:SS", or "SS[.m]") to time.Duration
+func Parse_Duration(value string) (time.Duration, error) {
+	seconds := 0.0
+	for _, part := range strings.Split(value, ":") {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		seconds = seconds*60 + number
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 // Run ffplay command, writing from IO stream
 func PlayData(wavData []byte) {
 	ffplay := exec.Command("aplay")
@@ -69,56 +152,97 @@ func PlayData(wavData []byte) {
 //	  [output-wav] \
 //	  [output-images]
 func Extract_Arguments(infile string, outdir string) []string {
-	return []string{
+	args := []string{
 		// basic-options input-mkv
 		"-y", "-loglevel", "warning", "-nostdin", "-nostats", "-i", infile,
 		// wav-to-stdout
-		"-map", "0:a:0", "-f", "s16le", "-ar", "48000", "-ac", "1", "-",
+		"-map", "0:a:0", "-f", "s16le"}
+	args = append(args, audio_format(false)...)
+	args = append(args, "-",
 		// output-wav
 		"-f", "segment", "-segment_time", "1", "-reset_timestamps", "1", outdir + "/%d.wav",
 		// output-images
-		"-map", "0:v:0", "-vf", "fps=1,scale=1536:864", "-start_number", "0", outdir + "/%d.jpg"}
+		"-map", "0:v:0", "-vf", "fps=1,scale=1536:864", "-start_number", "0", outdir + "/%d.jpg")
+	return args
+}
+
+// Return list of arguments for ffmpeg that:
+//
+//	Reads Audio to Stream.
+//
+//	ffmpeg [basic-options] [input-mkv] \
+//	  [wav-to-stdout]
+func Audio_Arguments(infile string) []string {
+	args := []string{
+		// basic-options input-mkv
+		"-y", "-loglevel", "warning", "-nostdin", "-nostats", "-i", infile,
+		// wav-to-stdout
+		"-map", "0:a:0", "-f", "s16le"}
+	args = append(args, audio_format(false)...)
+	return append(args, "-")
+}
+
+// Return list of arguments for ffmpeg that:
+//
+//	Copies (without re-encoding) part of a recording to a new file.
+//
+//	ffmpeg [basic-options] [seek] [input-mkv] \
+//	  [length] [copy-all] [output-mkv]
+func Clip_Arguments(infile string, start time.Duration, length time.Duration, outfile string) []string {
+	return []string{
+		// basic-options
+		"-y", "-loglevel", "warning", "-nostdin", "-nostats",
+		// seek input-mkv
+		"-ss", fmt.Sprintf("%.3f", start.Seconds()), "-i", infile,
+		// length copy-all output-mkv
+		"-t", fmt.Sprintf("%.3f", length.Seconds()), "-map", "0", "-c", "copy", outfile}
 }
 
 // Return list of arguments for ffmpeg that:
 //
-//	Saves A/V to MKV and Audio to Stream.
+//	Saves A/V to MKV and Audio to Stream, without stopping.
+//	A new MKV is started every Record_Duration, aligned to the wall clock.
 //
 //	ffmpeg [basic-options] \
 //	  [audio-options] [audio-device] \
 //	  [video-options] [video-device] \
-//	  [output-wav] [to-stdout] \
-//	  [output-wav&vid] [to-mkv] [MISSING:filename]
-func Recorder_Arguments() []string {
+//	  [output-pcm] [to-stdout] \
+//	  [output-wav&vid] [to-mkv-segments]
+func Recorder_Arguments(save_path string) []string {
 	// basic-options
 	args := []string{
 		"-y", "-loglevel", "warning", "-nostdin", "-nostats",
 		"-guess_layout_max", "1"}
 
 	// audio-options
-	args = append(args, "-t", state.Runtime.Record_Duration)
 	args = append(args, state.Runtime.Record_Audio_Options...)
 	// audio-device
 	args = append(args, "-i", state.Runtime.Record_Audio_Device)
 
 	// video-options
-	args = append(args, "-t", state.Runtime.Record_Duration)
 	args = append(args, state.Runtime.Record_Video_Options...)
 	// video-device
 	args = append(args, "-i", state.Runtime.Record_Video_Device)
 
-	// wav-to-stdout
+	// pcm-to-stdout (raw, so a restart never injects a header)
 	if state.Runtime.Has_Models {
-		args = append(args,
-			"-map", "0:a", "-c:a", "pcm_s16le",
-			"-ar", "48000", "-ac", "1", "-f", "wav", "-")
+		args = append(args, "-map", "0:a", "-c:a", "pcm_s16le")
+		args = append(args, audio_format(true)...)
+		args = append(args, "-f", "s16le", "-")
 	}
-	// wav&vid-to-mkv
+	// wav&vid-to-mkv (the same channel that is inspected)
 	args = append(args,
 		"-filter_complex", "[1:v]" + state.Runtime.Record_Video_Timestamp + "[dtstamp]",
-		"-map", "0:a", "-map", "[dtstamp]", "-c:a", "pcm_s16le",
-		"-ar", "48000", "-ac", "1", "-c:v")
+		"-map", "0:a", "-map", "[dtstamp]", "-c:a", "pcm_s16le")
+	args = append(args, audio_format(true)...)
+	args = append(args, "-c:v")
 	args = append(args, state.Runtime.Record_Video_Advanced...)
+	// to-mkv-segments
+	args = append(args,
+		"-f", "segment", "-segment_format", "matroska",
+		"-segment_time", state.Runtime.Record_Duration, "-segment_atclocktime", "1",
+		"-reset_timestamps", "1", "-strftime", "1",
+		filepath.Join(save_path, SegmentName))
 
 	log.Debug("Compiled recorder arguments: %s", args)
 	return args
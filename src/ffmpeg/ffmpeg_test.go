@@ -6,8 +6,11 @@ import (
 	"dtrack/ffmpeg"
 
 	// Standard
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 // Mock the state package's Runtime for testing Recorder_Arguments
@@ -51,6 +54,26 @@ func TestExtractArguments(t *testing.T) {
 	}
 }
 
+// Checks if the arguments for audio-only extraction are correctly formed.
+func TestAudioArguments(t *testing.T) {
+	t.Parallel()
+	infile := "test.mkv"
+
+	// Expected arguments array
+	expected := []string{
+		// basic-options input-mkv
+		"-y", "-loglevel", "warning", "-nostdin", "-nostats", "-i", infile,
+		// wav-to-stdout
+		"-map", "0:a:0", "-f", "s16le", "-ar", "48000", "-ac", "1", "-",
+	}
+
+	actual := ffmpeg.Audio_Arguments(infile)
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Audio_Arguments returned incorrect arguments.\nExpected: %v\nActual:   %v", expected, actual)
+	}
+}
+
 // Checks if the arguments for recording are correctly formed based on mocked state.
 func TestRecorderArguments(t *testing.T) {
 	t.Parallel()
@@ -62,27 +85,162 @@ func TestRecorderArguments(t *testing.T) {
 		// basic-options
 		"-y", "-loglevel", "warning", "-nostdin", "-nostats", "-guess_layout_max", "1",
 		// audio-options
-		"-t", "10", "-f", "alsa",
+		"-f", "alsa",
 		// audio-device
 		"-i", "hw:0",
 		// video-options
-		"-t", "10", "-f", "v4l2", "-framerate", "30",
+		"-f", "v4l2", "-framerate", "30",
 		// video-device
 		"-i", "/dev/video0",
-		// wav-to-stdout (Has_Models is true)
-		"-map", "0:a", "-c:a", "pcm_s16le", "-ar", "48000", "-ac", "1", "-f", "wav", "-",
+		// pcm-to-stdout (Has_Models is true)
+		"-map", "0:a", "-c:a", "pcm_s16le", "-ar", "48000", "-ac", "1", "-f", "s16le", "-",
 		// wav&vid-to-mkv
 		"-filter_complex", "[1:v]drawtext=text='%{localtime}':fontcolor=white:fontsize=24:x=10:y=10[dtstamp]",
 		"-map", "0:a", "-map", "[dtstamp]", "-c:a", "pcm_s16le",
 		"-ar", "48000", "-ac", "1", "-c:v",
 		"libx264", "-preset", "ultrafast", // Added from mocked Record_Video_Advanced
+		// to-mkv-segments
+		"-f", "segment", "-segment_format", "matroska",
+		"-segment_time", "10", "-segment_atclocktime", "1",
+		"-reset_timestamps", "1", "-strftime", "1",
+		"/tmp/recordings/%Y-%m-%d_%H%M%S.mkv",
 	}
 
 	// 3. Get actual arguments
-	actual := ffmpeg.Recorder_Arguments()
+	actual := ffmpeg.Recorder_Arguments("/tmp/recordings")
 
 	// 4. Verify using reflect.DeepEqual
 	if !reflect.DeepEqual(expected, actual) {
 		t.Errorf("Recorder_Arguments returned incorrect arguments.\nExpected: %v\nActual:   %v", expected, actual)
 	}
 }
+
+// Configured sample rate and channel reach capture (and inspection) arguments
+// Not parallel: other tests expect the default sample rate.
+func TestRecorderArguments_AudioFormat(t *testing.T) {
+	setupMockState()
+	defer func() { state.Runtime = state.Application_Configuration{} }()
+	state.Runtime.Record_Audio_Rate = 16000
+	state.Runtime.Record_Audio_Channel = 2
+
+	if ffmpeg.Bytes_Per_Second() != 32000 {
+		t.Errorf("Expected 32000 bytes per second, got %d", ffmpeg.Bytes_Per_Second())
+	}
+
+	// Both the inspected stream and the mkv use only the second channel
+	selected := []string{"-ar", "16000", "-ac", "1", "-af", "pan=mono|c0=c1"}
+	args := ffmpeg.Recorder_Arguments("/tmp/recordings")
+	found := 0
+	for i := range args {
+		if i+len(selected) <= len(args) && reflect.DeepEqual(args[i:i+len(selected)], selected) {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("Expected %v twice, found %d times in %v", selected, found, args)
+	}
+
+	// Recordings are already a single channel
+	expected := []string{
+		"-y", "-loglevel", "warning", "-nostdin", "-nostats", "-i", "test.mkv",
+		"-map", "0:a:0", "-f", "s16le", "-ar", "16000", "-ac", "1", "-",
+	}
+	if actual := ffmpeg.Audio_Arguments("test.mkv"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Audio_Arguments returned incorrect arguments.\nExpected: %v\nActual:   %v", expected, actual)
+	}
+}
+
+// Checks if the arguments for clipping are correctly formed.
+func TestClipArguments(t *testing.T) {
+	t.Parallel()
+	expected := []string{
+		"-y", "-loglevel", "warning", "-nostdin", "-nostats",
+		"-ss", "62.500", "-i", "in.mkv",
+		"-t", "40.000", "-map", "0", "-c", "copy", "out.mkv",
+	}
+
+	actual := ffmpeg.Clip_Arguments("in.mkv", 62500*time.Millisecond, 40*time.Second, "out.mkv")
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Clip_Arguments returned incorrect arguments.\nExpected: %v\nActual:   %v", expected, actual)
+	}
+}
+
+// Checks that ffmpeg-style durations are parsed.
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+	tests := map[string]time.Duration{
+		"00:10:00": 10 * time.Minute,
+		"01:00:05": time.Hour + 5*time.Second,
+		"02:30":    150 * time.Second,
+		"10":       10 * time.Second,
+		"1.5":      1500 * time.Millisecond,
+	}
+	for value, expected := range tests {
+		actual, err := ffmpeg.Parse_Duration(value)
+		if err != nil || actual != expected {
+			t.Errorf("Parse_Duration(%q) = %s, %v; want %s", value, actual, err, expected)
+		}
+	}
+	for _, value := range []string{"", "ten", "00:-1:00"} {
+		if _, err := ffmpeg.Parse_Duration(value); err == nil {
+			t.Errorf("Parse_Duration(%q) should fail", value)
+		}
+	}
+}
+
+// Checks that recordings are listed in order, with non-overlapping times.
+func TestListRecordings(t *testing.T) {
+	state.Runtime = state.Application_Configuration{
+		Workspace:       t.TempDir(),
+		Record_Duration: "00:10:00",
+	}
+	if err := os.MkdirAll(ffmpeg.Recordings_Directory(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"2025-06-01_221000.mkv", "2025-06-01_220000.mkv", "notes.txt"} {
+		path := filepath.Join(ffmpeg.Recordings_Directory(), name)
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recordings, err := ffmpeg.List_Recordings()
+	if err != nil {
+		t.Fatalf("List_Recordings failed: %v", err)
+	}
+	if len(recordings) != 2 {
+		t.Fatalf("Expected 2 recordings, got %d", len(recordings))
+	}
+	first, second := recordings[0], recordings[1]
+	if first.Name != "2025-06-01_220000.mkv" || first.Size != 4 {
+		t.Errorf("Unexpected first recording: %+v", first)
+	}
+	if !first.End.Equal(second.Start) || second.End.Sub(second.Start) != 10*time.Minute {
+		t.Errorf("Unexpected recording times: %+v, %+v", first, second)
+	}
+}
+
+// Rotation follows the wall clock, not the start of the recording
+func TestNext_Rotation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		start    time.Time
+		duration time.Duration
+		expected time.Time
+	}{
+		{time.Date(2025, 6, 1, 22, 3, 17, 0, time.Local), 10 * time.Minute,
+			time.Date(2025, 6, 1, 22, 10, 0, 0, time.Local)},
+		{time.Date(2025, 6, 1, 22, 10, 0, 0, time.Local), 10 * time.Minute,
+			time.Date(2025, 6, 1, 22, 20, 0, 0, time.Local)},
+		{time.Date(2025, 6, 1, 23, 55, 0, 0, time.Local), 7 * time.Minute,
+			time.Date(2025, 6, 2, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		actual := ffmpeg.Next_Rotation(tt.start, tt.duration)
+		if !actual.Equal(tt.expected) {
+			t.Errorf("Next_Rotation(%s, %s): expected %s, got %s",
+				tt.start, tt.duration, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ffmpeg
+
+import (
+	// DTrack
+	"dtrack/state"
+
+	// Standard
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Recording saved by the monitor, named using SaveName
+type Recording struct {
+	Name  string    // Filename (no directory)
+	Path  string    // Full path to recording
+	Size  int64     // Bytes on disk
+	Start time.Time // Wall-clock time when recording began
+	End   time.Time // Start of next recording, or Start + Record_Duration
+}
+
+// Returns the directory holding all recordings
+func Recordings_Directory() string {
+	return filepath.Join(state.Runtime.Workspace, "recordings")
+}
+
+// List all recordings, oldest first; unrecognized files are ignored
+func List_Recordings() ([]Recording, error) {
+	entries, err := os.ReadDir(Recordings_Directory())
+	if os.IsNotExist(err) {
+		return []Recording{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	duration, err := Parse_Duration(state.Runtime.Record_Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	recordings := []Recording{}
+	for _, entry := range entries {
+		start, err := time.ParseInLocation(SaveName, entry.Name(), time.Local)
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		recordings = append(recordings, Recording{
+			Name:  entry.Name(),
+			Path:  filepath.Join(Recordings_Directory(), entry.Name()),
+			Size:  info.Size(),
+			Start: start,
+			End:   start.Add(duration),
+		})
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].Start.Before(recordings[j].Start)
+	})
+
+	// Recordings never overlap the next recording
+	for i := 0; i+1 < len(recordings); i++ {
+		if recordings[i].End.After(recordings[i+1].Start) {
+			recordings[i].End = recordings[i+1].Start
+		}
+	}
+	return recordings, nil
+}
+
+// Returns the first wall-clock rotation after a recording begins
+// The segment muxer splits every duration (and at midnight), counting from
+// local midnight.
+func Next_Rotation(start time.Time, duration time.Duration) time.Time {
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	tomorrow := midnight.AddDate(0, 0, 1)
+	if duration <= 0 {
+		return tomorrow
+	}
+	rotation := midnight.Add((start.Sub(midnight)/duration + 1) * duration)
+	if rotation.After(tomorrow) {
+		return tomorrow
+	}
+	return rotation
+}
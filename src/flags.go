@@ -18,6 +18,27 @@ var (
 	app_config_path = flag.String(
 		"c", "./config.json",
 		"Path to configuration file")
+	app_input = flag.String(
+		"i", "",
+		"Input file or directory")
+	app_json = flag.Bool(
+		"j", false,
+		"Machine-readable (JSON) output.")
+	app_dry_run = flag.Bool(
+		"n", false,
+		"Dry run; only list recordings that retention would remove.")
+	app_output = flag.String(
+		"o", "",
+		"Output file or directory")
+	app_range = flag.Bool(
+		"r", false,
+		"Export the entire time range, not only incidents.")
+	app_since = flag.String(
+		"s", "",
+		"First day (YYYY-MM-DD) to include")
+	app_until = flag.String(
+		"u", "",
+		"Last day (YYYY-MM-DD) to include")
 	app_keep_temp = flag.Bool(
 		"k", false,
 		"Keep temporary files.")
@@ -35,7 +56,7 @@ func parse_flags() {
 	flag.Parse()
 
 	// Safety checks
-	okay_actions := []string{"monitor", "review", "train", "record"}
+	okay_actions := []string{"monitor", "review", "train", "record", "inspect", "report", "export", "verify", "prune", "dsp-check"}
 	if !In_List(*app_action, okay_actions) {
 		show_help()
 		log.Die("Unexpected Action: %s", *app_action)
@@ -49,6 +70,13 @@ func show_help() {
 	//flag.PrintDefaults()
 	fmt.Println("    -a action\tApplication action (See Actions, above) (default: <none>)")
 	fmt.Println("    -c path\tPath to configuration file (default: ./config.json)")
+	fmt.Println("    -i path\tInput file or directory (inspect, dsp-check)")
+	fmt.Println("    -j\t\tMachine-readable (JSON) output (inspect, dsp-check)")
+	fmt.Println("    -n\t\tDry run; only list recordings that would be removed (monitor, prune)")
+	fmt.Println("    -o path\tOutput file or directory (report, export, dsp-check)")
+	fmt.Println("    -r\t\tExport the entire time range, not only incidents (export)")
+	fmt.Println("    -s date\tFirst day to include, as YYYY-MM-DD [HH:MM:SS] (report, export)")
+	fmt.Println("    -u date\tLast day to include, as YYYY-MM-DD [HH:MM:SS] (report, export)")
 	fmt.Println("    -k\t\tKeep temporary files")
 	fmt.Println("    -v\t\tEnable verbose logging")
 	fmt.Println("    -V\t\tLike -v, but more")
@@ -57,11 +85,23 @@ func show_help() {
 	fmt.Println("    monitor\tCollect recordings and automatically review")
 	fmt.Println("    review\tManually review collected logs")
 	fmt.Println("    train\tTrain a new AI Model")
+	fmt.Println("    inspect\tCheck recordings (-i) against trained models")
+	fmt.Println("    report\tSummarize recorded incidents as HTML")
+	fmt.Println("    export\tBundle clips of incidents as evidence")
+	fmt.Println("    verify\tCheck recordings and detections against the ledger")
+	fmt.Println("    prune\tRemove old recordings based on retention options")
+	fmt.Println("    dsp-check\tPrint the spectrogram of a tagged clip (-i), to compare with Python")
 	fmt.Println("\nConfiguration Options:")
 	fmt.Println("    https://mtecknology.github.io/dtrack/setup/options")
 	fmt.Println("\nExamples:")
 	fmt.Println("    DTRACK_RECORD_DURATION=00:05:00  dtrack -a monitor")
+	fmt.Println("    RECORD_TRUST_OVERRIDES=dog:siren=0.9,dog=0.5  dtrack -a monitor")
 	fmt.Println("    dtrack -a review")
+	fmt.Println("    dtrack -a inspect -i _workspace/recordings -j")
+	fmt.Println("    dtrack -a report -s 2025-06-01 -u 2025-06-30")
+	fmt.Println("    dtrack -a export -s 2025-06-01 -u 2025-06-01 -o evidence.zip")
+	fmt.Println("    RETAIN_MAX_DAYS=30  dtrack -a prune -n")
+	fmt.Println("    dtrack -a dsp-check -i _workspace/tagged/clap/clip.dat -o clip.go.mel")
 }
 
 // Returns true if a search string is present in a list of slices
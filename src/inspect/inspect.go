@@ -0,0 +1,226 @@
+// ##
+// DTrack Package: Manual Inspection
+//
+// Runs all inspection models against recorded (.mkv) or tagged (.dat) files
+// and prints every match, like the monitor would have logged it.
+// ##
+package inspect
+
+import (
+	// DTrack
+	"dtrack/decision"
+	"dtrack/events"
+	"dtrack/ffmpeg"
+	"dtrack/log"
+	"dtrack/model"
+	"dtrack/state"
+
+	// Standard
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Detector (e.g. a trained model), as named in Record_Inspect_Models
+type named_model struct {
+	name     string
+	detector model.Detector
+}
+
+// Primary post-bootstrap entry point
+// Inspect a single file, or every supported file inside a directory
+func Run(input_path string, json_output bool) {
+	if !state.Runtime.Has_Models {
+		log.Die("No inspection models configured; nothing to inspect with!")
+	}
+	if input_path == "" {
+		log.Die("No input path provided (-i)")
+	}
+
+	// Load all configured models (including those only used by ensembles)
+	scanned := state.Runtime.Scanned_Models()
+	models := make([]named_model, 0, len(scanned))
+	for _, name := range scanned {
+		detector, err := model.New_Detector(state.Runtime.Detector(name),
+			state.Runtime.Workspace+"/models/"+name+".onnx")
+		if err != nil {
+			log.Die("Unable to load model %s: %s", name, err)
+		}
+		models = append(models, named_model{name: name, detector: detector})
+	}
+
+	files, err := list_files(input_path)
+	if err != nil {
+		log.Die("Unable to read input: %s", err)
+	}
+
+	// Print each match as it is found
+	report := print_text
+	if json_output {
+		encoder := json.NewEncoder(os.Stdout)
+		report = func(detection events.Detection) {
+			encoder.Encode(detection)
+		}
+	}
+	for _, path := range files {
+		log.Debug("Inspecting %s", path)
+		// Tagged clips are a single window; smoothing would never match
+		smoothing := decision.Configured(&state.Runtime)
+		if filepath.Ext(path) == ".dat" {
+			smoothing = decision.Smoothing{}
+		}
+		if err := inspect_file(path, models_matcher(models, smoothing, report)); err != nil {
+			log.Warn("Failed to inspect %s: %s", path, err)
+		}
+	}
+}
+
+// Returns a file, or the sorted list of .mkv/.dat files inside a directory
+func list_files(input_path string) ([]string, error) {
+	info, err := os.Stat(input_path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{input_path}, nil
+	}
+
+	entries, err := os.ReadDir(input_path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".mkv" || ext == ".dat") {
+			files = append(files, filepath.Join(input_path, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Pass each check window from a file to handler, with its offset in seconds
+// Windows are 2 seconds long with 1 second of overlap, matching the monitor.
+func inspect_file(path string, handler func(path string, offset int, window []byte)) error {
+	switch filepath.Ext(path) {
+	case ".dat":
+		// Tagged audio is a single check window
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		handler(path, 0, data)
+		return nil
+	case ".mkv":
+		stream, writer := io.Pipe()
+		go ffmpeg.ReadStdin(ffmpeg.Audio_Arguments(path), writer, true)
+		Slice_Windows(stream, func(offset int, window []byte) {
+			handler(path, offset, window)
+		})
+		return nil
+	default:
+		return fmt.Errorf("unsupported file type: %s", filepath.Ext(path))
+	}
+}
+
+// Split a raw PCM stream into overlapping check windows
+// The window buffer is reused; handlers must copy any window they keep.
+func Slice_Windows(stream io.Reader, handler func(offset int, window []byte)) {
+	var last_segment []byte
+	window := make([]byte, 0, 2*ffmpeg.Bytes_Per_Second())
+	for offset := -1; ; offset++ {
+		// Block until segment is full
+		segment := make([]byte, ffmpeg.Bytes_Per_Second())
+		if _, err := io.ReadFull(stream, segment); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				log.Warn("Unhandled stream read error: %s", err)
+			}
+			return
+		}
+
+		// Delay processing until a second segment is available
+		if last_segment != nil {
+			window = append(append(window[:0], last_segment...), segment...)
+			handler(offset, window)
+		}
+		last_segment = segment
+	}
+}
+
+// Returns a handler that runs all models (and ensembles) against each check window
+// Decisions are smoothed across the windows of each file, like the monitor.
+func models_matcher(models []named_model, smoothing decision.Smoothing, report func(events.Detection)) func(string, int, []byte) {
+	smoothers := make(map[string]*decision.Smoother)
+	for _, m := range models {
+		smoothers[m.name] = decision.New(smoothing, func(class string) float64 {
+			return state.Runtime.Trust(m.name, class)
+		})
+	}
+
+	return func(path string, offset int, window []byte) {
+		// Prepared once for each DSP setting used by the models
+		shared := []*model.Window{model.New_Window(window)}
+
+		decisions := make(map[string]decision.Decision)
+		for _, m := range models {
+			predictions, err := m.detector.Detect(shared)
+			if err != nil {
+				log.Warn("%s @%d: %s", path, offset, err)
+				continue
+			}
+			result := smoothers[m.name].Update(predictions[0])
+			decisions[m.name] = result
+			if !state.Runtime.Reported(m.name) {
+				continue
+			}
+			if !result.Matched {
+				log.Trace("%s @%d: No match for %s. Top: %s (Conf: %.4f)",
+					path, offset, m.name, result.Class, result.Confidence)
+				continue
+			}
+			report(matched(path, offset, m.name, result))
+		}
+
+		// Ensembles vote once every model has decided the window
+		for _, ensemble := range state.Runtime.Record_Ensembles {
+			trust := state.Runtime.Trust(ensemble.Name, ensemble.Class)
+			if result := decision.Combine(ensemble, decisions, trust); result.Matched {
+				report(matched(path, offset, ensemble.Name, result))
+			}
+		}
+	}
+}
+
+// Detection of a matched decision
+func matched(path string, offset int, name string, result decision.Decision) events.Detection {
+	return events.Detection{
+		Time:          recording_time(path, offset),
+		Model:         name,
+		Class:         result.Class,
+		Confidence:    result.Confidence,
+		Probabilities: result.Probabilities,
+		Recording:     filepath.Base(path),
+		Offset:        offset,
+	}
+}
+
+// Wall-clock time of an offset, if the file is named like a recording
+func recording_time(path string, offset int) time.Time {
+	started, err := time.ParseInLocation(ffmpeg.SaveName, filepath.Base(path), time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return started.Add(time.Duration(offset) * time.Second)
+}
+
+// Print a detection in human-readable form
+func print_text(detection events.Detection) {
+	fmt.Printf("Matched %s/%s in %s @%d sec (Conf: %.4f)\n",
+		detection.Model, detection.Class,
+		detection.Recording, detection.Offset, detection.Confidence)
+}
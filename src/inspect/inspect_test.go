@@ -0,0 +1,38 @@
+package inspect_test
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+	"dtrack/inspect"
+	"dtrack/model"
+
+	// Standard
+	"bytes"
+	"testing"
+)
+
+// Streams are sliced into 2-second windows that overlap by 1 second
+func TestSlice_Windows(t *testing.T) {
+	// Four full seconds (each filled with its own index) plus a partial second
+	stream := new(bytes.Buffer)
+	for second := 0; second < 4; second++ {
+		stream.Write(bytes.Repeat([]byte{byte(second)}, ffmpeg.Bytes_Per_Second()))
+	}
+	stream.Write(make([]byte, ffmpeg.Bytes_Per_Second()/2))
+
+	offsets := []int{}
+	inspect.Slice_Windows(stream, func(offset int, window []byte) {
+		offsets = append(offsets, offset)
+		if len(window) != model.Sample_Size() {
+			t.Errorf("Window @%d: expected %d bytes, got %d", offset, model.Sample_Size(), len(window))
+		}
+		// First half belongs to offset, second half to offset+1
+		if window[0] != byte(offset) || window[len(window)-1] != byte(offset+1) {
+			t.Errorf("Window @%d holds the wrong audio", offset)
+		}
+	})
+
+	if len(offsets) != 3 || offsets[0] != 0 || offsets[2] != 2 {
+		t.Errorf("Expected windows at [0 1 2], got %v", offsets)
+	}
+}
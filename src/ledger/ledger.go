@@ -0,0 +1,216 @@
+// ##
+// DTrack Package: Evidence Ledger
+//
+// Hash chain over every finished recording and detection. Each entry includes
+// the hash of the entry before it, so any edit to the ledger (or the files it
+// describes) can be found with the "verify" action.
+// ##
+package ledger
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/state"
+
+	// Standard
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Ledger file, relative to <workspace>/events/
+const Ledger = "ledger.jsonl"
+
+// Kinds of ledger entries
+const (
+	Kind_Recording = "recording" // Finished recording file
+	Kind_Detection = "detection" // Single line of detections.jsonl
+	Kind_Deleted   = "deleted"   // Recording intentionally removed
+)
+
+// Single link in the hash chain
+type Entry struct {
+	Sequence  int       `json:"sequence"`
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	SHA256    string    `json:"sha256"`
+	Bytes     int64     `json:"bytes"`
+	Previous  string    `json:"previous"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+// Chain position, loaded from disk on first use (per workspace)
+var (
+	chain_lock      sync.Mutex
+	chain_workspace string
+	last_entry      Entry
+)
+
+// Add a finished recording to the ledger
+func Record_File(path string) error {
+	sum, size, err := Hash_File(path)
+	if err != nil {
+		return err
+	}
+	return append_entry(Kind_Recording, filepath.Base(path), sum, size)
+}
+
+// Note that a recording was intentionally removed (e.g. by retention)
+func Record_Deleted(path string) error {
+	return append_entry(Kind_Deleted, filepath.Base(path), "", 0)
+}
+
+// Save a detection to the event store, and its hash to the ledger
+// Both are written under one lock so ledger order matches the event store.
+func Record_Detection(detection events.Detection) error {
+	line, err := json.Marshal(detection)
+	if err != nil {
+		return err
+	}
+
+	chain_lock.Lock()
+	defer chain_lock.Unlock()
+	if err := events.Record_Detection(detection); err != nil {
+		return err
+	}
+	return append_locked(Kind_Detection, events.Detections, Hash_Bytes(line), int64(len(line)))
+}
+
+// Lock the chain and append a new entry
+func append_entry(kind string, name string, sum string, size int64) error {
+	chain_lock.Lock()
+	defer chain_lock.Unlock()
+	return append_locked(kind, name, sum, size)
+}
+
+// Append a new entry; chain_lock must be held
+func append_locked(kind string, name string, sum string, size int64) error {
+	if chain_workspace != state.Runtime.Workspace {
+		entries, err := Load()
+		if err != nil {
+			return err
+		}
+		last_entry = Entry{}
+		if len(entries) > 0 {
+			last_entry = entries[len(entries)-1]
+		}
+		chain_workspace = state.Runtime.Workspace
+	}
+
+	entry := Entry{
+		Sequence: last_entry.Sequence + 1,
+		Time:     time.Now(),
+		Kind:     kind,
+		Name:     name,
+		SHA256:   sum,
+		Bytes:    size,
+		Previous: last_entry.Hash,
+	}
+	entry.Hash = entry.compute_hash()
+
+	// Optional signature using the device key
+	if state.Runtime.Ledger_Sign {
+		key, err := Device_Key()
+		if err != nil {
+			return err
+		}
+		entry.Signature = hex.EncodeToString(ed25519.Sign(key, []byte(entry.Hash)))
+	}
+
+	if err := events.Append(Ledger, entry); err != nil {
+		return err
+	}
+	last_entry = entry
+	return nil
+}
+
+// Hash of an entry, excluding its own hash and signature
+func (e Entry) compute_hash() string {
+	e.Hash = ""
+	e.Signature = ""
+	data, _ := json.Marshal(e)
+	return Hash_Bytes(data)
+}
+
+// Read every ledger entry
+func Load() ([]Entry, error) {
+	return events.Read[Entry](Ledger)
+}
+
+// Returns the SHA-256 (hex) of a byte slice
+func Hash_Bytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the SHA-256 (hex) and size of a file
+func Hash_File(path string) (string, int64, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer fh.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, fh)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// Directory holding the device signing key
+func key_directory() string {
+	return filepath.Join(state.Runtime.Workspace, "keys")
+}
+
+// Load the device signing key, generating a new key if none exists
+func Device_Key() (ed25519.PrivateKey, error) {
+	key_path := filepath.Join(key_directory(), "device.key")
+	if data, err := os.ReadFile(key_path); err == nil {
+		seed, err := hex.DecodeString(string(data))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid device key: %s", key_path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+
+	// Generate a new key pair
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(key_directory(), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(key_path, []byte(hex.EncodeToString(private.Seed())), 0600); err != nil {
+		return nil, err
+	}
+	public_path := filepath.Join(key_directory(), "device.pub")
+	if err := os.WriteFile(public_path, []byte(hex.EncodeToString(public)), 0644); err != nil {
+		return nil, err
+	}
+	return private, nil
+}
+
+// Load the device public key, if one exists
+func Device_Public_Key() (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(filepath.Join(key_directory(), "device.pub"))
+	if err != nil {
+		return nil, err
+	}
+	public, err := hex.DecodeString(string(data))
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid device public key")
+	}
+	return public, nil
+}
@@ -0,0 +1,193 @@
+package ledger_test
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/ffmpeg"
+	"dtrack/ledger"
+	"dtrack/state"
+
+	// Standard
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a workspace with two ledgered recordings and one detection
+func setupWorkspace(t *testing.T, sign bool) {
+	state.Runtime = state.Application_Configuration{
+		Workspace:       t.TempDir(),
+		Record_Duration: "00:10:00",
+		Ledger_Sign:     sign,
+	}
+	if err := os.MkdirAll(ffmpeg.Recordings_Directory(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"2025-06-01_220000.mkv", "2025-06-01_221000.mkv"} {
+		path := filepath.Join(ffmpeg.Recordings_Directory(), name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ledger.Record_File(path); err != nil {
+			t.Fatalf("Record_File failed: %v", err)
+		}
+	}
+	detection := events.Detection{
+		Time:          time.Date(2025, 6, 1, 22, 5, 0, 0, time.UTC),
+		Model:         "dog",
+		Class:         "bark",
+		Confidence:    0.8,
+		Probabilities: map[string]float64{"bark": 0.8, "empty": 0.2},
+	}
+	if err := ledger.Record_Detection(detection); err != nil {
+		t.Fatalf("Record_Detection failed: %v", err)
+	}
+}
+
+// Verify a workspace, failing the test on errors
+func verify(t *testing.T) ledger.Result {
+	result, err := ledger.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return result
+}
+
+// Untouched workspaces pass verification
+func TestVerify_Clean(t *testing.T) {
+	for _, sign := range []bool{false, true} {
+		setupWorkspace(t, sign)
+		result := verify(t)
+		if !result.Ok() || result.Entries != 3 || len(result.Untracked) != 0 {
+			t.Errorf("Expected clean result (sign=%v), got %+v", sign, result)
+		}
+	}
+}
+
+// Modified and removed recordings are reported
+func TestVerify_Recordings(t *testing.T) {
+	setupWorkspace(t, false)
+	directory := ffmpeg.Recordings_Directory()
+	os.WriteFile(filepath.Join(directory, "2025-06-01_220000.mkv"), []byte("edited"), 0644)
+	os.Remove(filepath.Join(directory, "2025-06-01_221000.mkv"))
+	os.WriteFile(filepath.Join(directory, "2025-06-01_222000.mkv"), []byte("new"), 0644)
+
+	result := verify(t)
+	if len(result.Altered) != 1 || result.Altered[0] != "2025-06-01_220000.mkv" {
+		t.Errorf("Expected altered recording, got %v", result.Altered)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "2025-06-01_221000.mkv" {
+		t.Errorf("Expected missing recording, got %v", result.Missing)
+	}
+	if len(result.Untracked) != 1 || result.Untracked[0] != "2025-06-01_222000.mkv" {
+		t.Errorf("Expected untracked recording, got %v", result.Untracked)
+	}
+}
+
+// Intentionally deleted recordings are not missing
+func TestVerify_Deleted(t *testing.T) {
+	setupWorkspace(t, false)
+	path := filepath.Join(ffmpeg.Recordings_Directory(), "2025-06-01_221000.mkv")
+	os.Remove(path)
+	if err := ledger.Record_Deleted(path); err != nil {
+		t.Fatalf("Record_Deleted failed: %v", err)
+	}
+	if result := verify(t); !result.Ok() {
+		t.Errorf("Expected clean result, got %+v", result)
+	}
+}
+
+// Edited detections and ledger entries are reported
+func TestVerify_Tampered(t *testing.T) {
+	setupWorkspace(t, true)
+	edit := func(name string, old string, new string) {
+		path := filepath.Join(events.Directory(), name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0644)
+	}
+
+	// Detection confidence raised after the fact
+	edit(events.Detections, `"confidence":0.8`, `"confidence":0.9`)
+	result := verify(t)
+	if result.Ok() || len(result.Missing) != 1 || len(result.Untracked) != 1 {
+		t.Errorf("Expected edited detection to be reported, got %+v", result)
+	}
+
+	// Ledger entry rewritten
+	edit(ledger.Ledger, `"bytes":21`, `"bytes":22`)
+	result = verify(t)
+	if len(result.Broken) == 0 {
+		t.Errorf("Expected broken chain, got %+v", result)
+	}
+}
+
+// Remove the signature of the chosen entries, then recompute the hash chain
+func strip_signatures(t *testing.T, strip func(sequence int) bool) {
+	entries, err := ledger.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rewritten []byte
+	previous := ""
+	for _, entry := range entries {
+		signature := entry.Signature
+		if strip(entry.Sequence) {
+			signature = ""
+		}
+
+		// Hash excludes the hash and signature
+		entry.Previous, entry.Hash, entry.Signature = previous, "", ""
+		data, _ := json.Marshal(entry)
+		entry.Hash = ledger.Hash_Bytes(data)
+		entry.Signature = signature
+		previous = entry.Hash
+
+		line, _ := json.Marshal(entry)
+		rewritten = append(append(rewritten, line...), '\n')
+	}
+	os.WriteFile(filepath.Join(events.Directory(), ledger.Ledger), rewritten, 0644)
+}
+
+// Signatures stripped from a signed ledger are reported, even if the chain is rebuilt
+func TestVerify_Unsigned(t *testing.T) {
+	// Every signature removed; the device key shows the ledger was signed
+	setupWorkspace(t, true)
+	strip_signatures(t, func(int) bool { return true })
+	if result := verify(t); len(result.Broken) != 1 {
+		t.Errorf("Expected a ledger without signatures to be reported, got %+v", result)
+	}
+
+	// Only the latest signature removed
+	setupWorkspace(t, true)
+	strip_signatures(t, func(sequence int) bool { return sequence == 3 })
+	if result := verify(t); len(result.Broken) != 1 {
+		t.Errorf("Expected entry 3 to be reported unsigned, got %+v", result)
+	}
+}
+
+// Entries added before signing was enabled remain valid without a signature
+func TestVerify_SigningEnabled(t *testing.T) {
+	setupWorkspace(t, false)
+	state.Runtime.Ledger_Sign = true
+	path := filepath.Join(ffmpeg.Recordings_Directory(), "2025-06-01_222000.mkv")
+	os.WriteFile(path, []byte("signed"), 0644)
+	if err := ledger.Record_File(path); err != nil {
+		t.Fatalf("Record_File failed: %v", err)
+	}
+
+	if result := verify(t); !result.Ok() || result.Entries != 4 {
+		t.Errorf("Expected clean result, got %+v", result)
+	}
+
+	// The signed entry cannot be stripped back to the unsigned history
+	strip_signatures(t, func(sequence int) bool { return sequence == 4 })
+	if result := verify(t); len(result.Broken) != 1 {
+		t.Errorf("Expected a stripped signature to be reported, got %+v", result)
+	}
+}
@@ -0,0 +1,185 @@
+package ledger
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/ffmpeg"
+	"dtrack/log"
+
+	// Standard
+	"bufio"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Findings from a workspace verification
+type Result struct {
+	Entries   int      // Ledger entries checked
+	Broken    []string // Problems with the chain itself
+	Missing   []string // Ledgered files (or records) that no longer exist
+	Altered   []string // Files whose contents no longer match the ledger
+	Untracked []string // Files (or records) never added to the ledger
+}
+
+// Returns true if nothing has been removed or modified
+func (r Result) Ok() bool {
+	return len(r.Broken)+len(r.Missing)+len(r.Altered) == 0
+}
+
+// Primary post-bootstrap entry point
+// Re-hash the workspace and report anything that does not match the ledger
+func Run() {
+	result, err := Verify()
+	if err != nil {
+		log.Die("Unable to verify workspace: %s", err)
+	}
+
+	for _, problem := range result.Broken {
+		log.Warn("BROKEN CHAIN: %s", problem)
+	}
+	for _, name := range result.Missing {
+		log.Warn("MISSING: %s", name)
+	}
+	for _, name := range result.Altered {
+		log.Warn("ALTERED: %s", name)
+	}
+	for _, name := range result.Untracked {
+		log.Info("Untracked: %s", name)
+	}
+
+	if !result.Ok() {
+		log.Die("Verification FAILED (%d entries checked)", result.Entries)
+	}
+	log.Info("Verification passed (%d entries checked)", result.Entries)
+}
+
+// Check the hash chain, then compare the workspace against it
+func Verify() (Result, error) {
+	var result Result
+	entries, err := Load()
+	if err != nil {
+		return result, err
+	}
+	result.Entries = len(entries)
+	public, _ := Device_Public_Key()
+	signing := false // Every entry after the first signed entry must be signed
+
+	// Walk the chain
+	recordings := make(map[string]Entry)
+	detections := make(map[string]int)
+	previous := ""
+	for i, entry := range entries {
+		if entry.Sequence != i+1 {
+			result.Broken = append(result.Broken,
+				fmt.Sprintf("entry %d has sequence %d", i+1, entry.Sequence))
+		}
+		if entry.Previous != previous {
+			result.Broken = append(result.Broken,
+				fmt.Sprintf("entry %d does not follow entry %d", entry.Sequence, i))
+		}
+		if entry.Hash != entry.compute_hash() {
+			result.Broken = append(result.Broken,
+				fmt.Sprintf("entry %d was modified", entry.Sequence))
+		}
+		switch {
+		case entry.Signature != "":
+			signing = true
+			signature, err := hex.DecodeString(entry.Signature)
+			if public == nil || err != nil || !ed25519.Verify(public, []byte(entry.Hash), signature) {
+				result.Broken = append(result.Broken,
+					fmt.Sprintf("entry %d has an invalid signature", entry.Sequence))
+			}
+		case signing:
+			result.Broken = append(result.Broken,
+				fmt.Sprintf("entry %d is not signed", entry.Sequence))
+		}
+		previous = entry.Hash
+
+		switch entry.Kind {
+		case Kind_Recording:
+			recordings[entry.Name] = entry
+		case Kind_Deleted:
+			delete(recordings, entry.Name)
+		case Kind_Detection:
+			detections[entry.SHA256]++
+		}
+	}
+
+	// The device key is only created to sign an entry
+	if public != nil && !signing {
+		result.Broken = append(result.Broken, "device key exists, but no entry is signed")
+	}
+
+	// Re-hash every ledgered recording
+	for name, entry := range recordings {
+		sum, _, err := Hash_File(filepath.Join(ffmpeg.Recordings_Directory(), name))
+		switch {
+		case os.IsNotExist(err):
+			result.Missing = append(result.Missing, name)
+		case err != nil:
+			return result, err
+		case sum != entry.SHA256:
+			result.Altered = append(result.Altered, name)
+		}
+	}
+	files, err := ffmpeg.List_Recordings()
+	if err != nil {
+		return result, err
+	}
+	for _, file := range files {
+		if _, ok := recordings[file.Name]; !ok {
+			result.Untracked = append(result.Untracked, file.Name)
+		}
+	}
+
+	// Re-hash every detection record
+	lines, err := read_lines(filepath.Join(events.Directory(), events.Detections))
+	if err != nil {
+		return result, err
+	}
+	for i, line := range lines {
+		sum := Hash_Bytes(line)
+		if detections[sum] > 0 {
+			detections[sum]--
+		} else {
+			result.Untracked = append(result.Untracked,
+				fmt.Sprintf("%s line %d", events.Detections, i+1))
+		}
+	}
+	for _, count := range detections {
+		for ; count > 0; count-- {
+			result.Missing = append(result.Missing, events.Detections+" record")
+		}
+	}
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.Altered)
+	sort.Strings(result.Untracked)
+	return result, nil
+}
+
+// Read every non-empty line from a file; a missing file has no lines
+func read_lines(path string) ([][]byte, error) {
+	lines := [][]byte{}
+	fh, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return lines, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			lines = append(lines, append([]byte{}, scanner.Bytes()...))
+		}
+	}
+	return lines, scanner.Err()
+}
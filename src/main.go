@@ -14,7 +14,12 @@ import (
 
 	// Actions
 	"dtrack/daemon"
+	"dtrack/export"
+	"dtrack/inspect"
+	"dtrack/ledger"
 	"dtrack/model"
+	"dtrack/report"
+	"dtrack/retention"
 	"dtrack/review"
 )
 
@@ -31,10 +36,18 @@ func main() {
 
 	// Kickoff
 	action_map := map[string]func(){
-		"monitor": daemon.Run,
-		"record":  daemon.Run, // Alias
+		"monitor": func() { daemon.Run(*app_dry_run) },
+		"record":  func() { daemon.Run(*app_dry_run) }, // Alias
 		"review":  review.Launch,
 		"train":   model.Train,
+		"inspect": func() { inspect.Run(*app_input, *app_json) },
+		"report":  func() { report.Run(*app_since, *app_until, *app_output) },
+		"export":  func() { export.Run(*app_since, *app_until, *app_output, *app_range) },
+		"verify":  ledger.Run,
+		"prune":   func() { retention.Run(*app_dry_run) },
+		"dsp-check": func() {
+			model.DSP_Check(*app_input, *app_output, *app_json)
+		},
 	}
 	action_map[*app_action]()
 }
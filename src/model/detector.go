@@ -0,0 +1,76 @@
+package model
+
+import (
+	// DTrack
+	"dtrack/state"
+
+	// Standard
+	"bytes"
+	"encoding/json"
+
+	// 3rd-Party
+	"gorgonia.org/tensor"
+)
+
+// Anything that decides the class probabilities of check windows
+// Silent windows are decided "empty" by the caller, without a Detector.
+type Detector interface {
+	// Probabilities of each class, for each window (in order)
+	Detect(windows []*Window) ([]map[string]float64, error)
+	// Short description for logs (e.g. the hash of a trained model)
+	Describe() string
+}
+
+// Class of windows without a match (matches decision.Empty)
+const empty = "empty"
+
+// Create (and check) the detector configured for a model
+// ONNX models are loaded from model_path; other types only use their params.
+func New_Detector(config state.Detector, model_path string) (Detector, error) {
+	switch config.Type {
+	case state.Detector_Loudness:
+		return New_Loudness(config.Params)
+	case state.Detector_Band:
+		return New_Band(config.Params)
+	}
+
+	loaded, err := Load(model_path)
+	if err != nil {
+		return nil, err
+	}
+	if err := Check(loaded); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// Detect prepares each window with the model's DSP settings, then infers them (see Infer_Batch)
+func (m *OnnxModel) Detect(windows []*Window) ([]map[string]float64, error) {
+	prepared := make([]*tensor.Dense, 0, len(windows))
+	for _, window := range windows {
+		preparedAudio, err := window.Prepare(m.DSP)
+		if err != nil {
+			return nil, model_error(m, ErrInput, "%s", err)
+		}
+		prepared = append(prepared, preparedAudio)
+	}
+	return Infer_Batch(m, prepared)
+}
+
+// Describe an ONNX model by its hash
+func (m *OnnxModel) Describe() string {
+	return m.Hash
+}
+
+// Decode detector params into settings (holding defaults); unknown params are rejected
+func decode_params(params json.RawMessage, settings any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(settings); err != nil {
+		return &Error{Kind: ErrParams, Err: err}
+	}
+	return nil
+}
@@ -1,9 +1,6 @@
 package model
 
 import (
-	// DTrack
-	"dtrack/ffmpeg"
-
 	// Standard
 	"math"
 )
@@ -12,12 +9,9 @@ const (
 	// HTK Mel Scale Constants (Matches Librosa defaults)
 	MelScalar = 2595.0
 	MelBreak  = 700.0
-)
 
-var (
-	// Cache initial values; Prevents recaclulating values 100x/sec
-	cachedMelWeights [][]float64  // The weights: [MelBin][LinearBin]
-	cachedMelBounds []filterBound // Filter Bounds: Prevent multilpy by zero
+	// Level reported for digital silence (below 16-bit resolution)
+	SilenceFloor = -120.0
 )
 
 // Simple container to store boundaries
@@ -26,17 +20,17 @@ type filterBound struct {
 	end   int
 }
 
-
-// Pre-calculate the Mel Spetrogram "Lens"
-func init() {
+// Calculate the Mel Spectrogram "Lens" of one DSP setting
+// Cached with the other tables of each setting (see tables_for)
+func mel_filterbank(dsp DSP) ([][]float64, []filterBound) {
 	// Calculate Matrix Dimensions
-	numLinearBins := Nfft/2 + 1
+	numLinearBins := dsp.Nfft/2 + 1
 	minMel := hzToMel(MinFreq)
-	maxMel := hzToMel(MaxFreq)
+	maxMel := hzToMel(float64(dsp.Sample_Rate) / 2)
 
 	// Create Mel Frequency Points
-	melPoints := make([]float64, Nmels+2)
-	step := (maxMel - minMel) / float64(Nmels+1)
+	melPoints := make([]float64, dsp.Nmels+2)
+	step := (maxMel - minMel) / float64(dsp.Nmels+1)
 
 	for i := 0; i < len(melPoints); i++ {
 		melPoints[i] = melToHz(minMel + float64(i)*step)
@@ -45,15 +39,15 @@ func init() {
 	// Convert Hz to FFT Bin Indices
 	binPoints := make([]int, len(melPoints))
 	for i, freq := range melPoints {
-		binPoints[i] = int(math.Floor((Nfft + 1) * freq / float64(ffmpeg.SampleRate)))
+		binPoints[i] = int(math.Floor(float64(dsp.Nfft+1) * freq / float64(dsp.Sample_Rate)))
 	}
 
 	// Generate Weights and Bounds
-	cachedMelWeights = make([][]float64, Nmels)
-	cachedMelBounds = make([]filterBound, Nmels)
+	melWeights := make([][]float64, dsp.Nmels)
+	melBounds := make([]filterBound, dsp.Nmels)
 
-	for i := 0; i < Nmels; i++ {
-		cachedMelWeights[i] = make([]float64, numLinearBins)
+	for i := 0; i < dsp.Nmels; i++ {
+		melWeights[i] = make([]float64, numLinearBins)
 
 		// Identify bounds
 		start := binPoints[i]
@@ -61,7 +55,7 @@ func init() {
 		end := binPoints[i+2]
 
 		// Store discovered bounds
-		cachedMelBounds[i] = filterBound{
+		melBounds[i] = filterBound{
 			start: start,
 			end:   end,
 		}
@@ -69,89 +63,42 @@ func init() {
 		// Triangle Weight: Rising edge
 		for f := start; f < center; f++ {
 			if f >= numLinearBins { break }
-			cachedMelWeights[i][f] = float64(f-start) / float64(center-start)
+			melWeights[i][f] = float64(f-start) / float64(center-start)
 		}
 		// Triangle Weight: Falling edge
 		for f := center; f < end; f++ {
 			if f >= numLinearBins { break }
-			cachedMelWeights[i][f] = float64(end-f) / float64(end-center)
+			melWeights[i][f] = float64(end-f) / float64(end-center)
 		}
 	}
+	return melWeights, melBounds
 }
 
-// Converts a Linear Power Spectrogram to a Mel Spectrogram; Replicates librosa.filters.mel()
-func applyMelFilterbank(powerSpec [][]float64, numLinearBins, numFrames int) [][]float64 {
-	// Initialize Output
-	melSpec := make([][]float64, Nmels)
-	for i := range melSpec {
-		melSpec[i] = make([]float64, numFrames)
-	}
-
-	// Loop Mel Bins (rows)
-	for m := 0; m < Nmels; m++ {
-		// Retrieve pre-calculated optimization bounds
-		start := cachedMelBounds[m].start
-		end := cachedMelBounds[m].end
-		if end > numLinearBins {
-			end = numLinearBins
-		}
-
-		// Loop Time Frames (columns)
-		for t := 0; t < numFrames; t++ {
-			sum := 0.0
-
-			// Only iterate where the filter is not zero
-			for k := start; k < end; k++ {
-				sum += cachedMelWeights[m][k] * powerSpec[k][t]
-			}
-			melSpec[m][t] = sum
-		}
+// Measure RMS and peak level of raw 16-bit PCM, in dBFS (0 is full scale)
+func Level(pcmData []byte) (rms float64, peak float64) {
+	numSamples := len(pcmData) / 2
+	if numSamples == 0 {
+		return SilenceFloor, SilenceFloor
 	}
-	return melSpec
-}
 
-// Clips DB to [-80, 0] and scales to [0.0, 1.0]
-func fixedNormalize(dbSpec [][]float64) [][]float64 {
-	rows := len(dbSpec)
-	cols := len(dbSpec[0])
-	norm := make([][]float64, rows)
-
-	for r := 0; r < rows; r++ {
-		norm[r] = make([]float64, cols)
-		for c := 0; c < cols; c++ {
-			val := dbSpec[r][c]
-			if val < -80.0 { val = -80.0 }
-			if val > 0.0 { val = 0.0 }
-			norm[r][c] = (val + 80.0) / 80.0
+	sumSquares := 0.0
+	maxAbs := 0.0
+	for i := 0; i < numSamples; i++ {
+		val := float64(int16(uint16(pcmData[i*2])|uint16(pcmData[i*2+1])<<8)) / Int16Max
+		sumSquares += val * val
+		if math.Abs(val) > maxAbs {
+			maxAbs = math.Abs(val)
 		}
 	}
-	return norm
+	return amplitudeToDb(math.Sqrt(sumSquares / float64(numSamples))), amplitudeToDb(maxAbs)
 }
 
-// Converts a power spectrogram to decibels
-func powerToDb(spec [][]float64) [][]float64 {
-	rows := len(spec)
-	cols := len(spec[0])
-	dbSpec := make([][]float64, rows)
-
-	// Find Global Max
-	var maxVal float64 = 1e-10
-	for r := 0; r < rows; r++ {
-		dbSpec[r] = make([]float64, cols)
-		for c := 0; c < cols; c++ {
-			if spec[r][c] > maxVal { maxVal = spec[r][c] }
-		}
-	}
-
-	// Log Calculation
-	for r := 0; r < rows; r++ {
-		for c := 0; c < cols; c++ {
-			val := spec[r][c]
-			if val < 1e-10 { val = 1e-10 }
-			dbSpec[r][c] = 10.0 * math.Log10(val/maxVal)
-		}
+// Internal Helper: Convert linear amplitude [0, 1] to dBFS
+func amplitudeToDb(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return SilenceFloor
 	}
-	return dbSpec
+	return math.Max(20.0*math.Log10(amplitude), SilenceFloor)
 }
 
 // Internal Helper: Convert Hz to Mel
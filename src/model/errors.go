@@ -0,0 +1,53 @@
+package model
+
+import (
+	// Standard
+	"errors"
+	"fmt"
+)
+
+// Kinds of model failure; match with errors.Is
+var (
+	ErrModelFile = errors.New("could not read ONNX file")
+	ErrLabels    = errors.New("invalid labels")
+	ErrGraph     = errors.New("invalid ONNX model")
+	ErrInput     = errors.New("unexpected prepared window")
+	ErrInference = errors.New("inference failed")
+	ErrOutput    = errors.New("unexpected model output")
+	ErrParams    = errors.New("invalid detector params")
+)
+
+// Failure loading or running a single model
+type Error struct {
+	Path string // Model file; empty for models not loaded from a file
+	Kind error  // One of the Err* values
+	Err  error  // Underlying cause (if any)
+}
+
+func (e *Error) Error() string {
+	message := e.Kind.Error()
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	if e.Path != "" {
+		message = e.Path + ": " + message
+	}
+	return message
+}
+
+// Matches both the kind of failure and its cause
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Build a model error, with a formatted cause
+func model_error(m *OnnxModel, kind error, format string, args ...any) *Error {
+	failure := &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+	if m != nil {
+		failure.Path = m.Path
+	}
+	return failure
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	// DTrack
+	"dtrack/log"
+
+	// Standard
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Largest difference (after normalization) accepted between Go and Python spectrograms
+const GoldenTolerance = 1e-3
+
+// Read a spectrogram saved as little-endian float32 [Nmels x Frames]
+// Golden spectrograms are written by ai/golden.py, from the training DSP.
+func Read_Spectrogram(path string, dsp DSP) ([]float32, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != dsp.Nmels*dsp.Frames*4 {
+		return nil, fmt.Errorf("%s holds %d bytes, expected %d", path, len(raw), dsp.Nmels*dsp.Frames*4)
+	}
+	values := make([]float32, dsp.Nmels*dsp.Frames)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return values, nil
+}
+
+// Save a spectrogram in the same format as Read_Spectrogram
+func Write_Spectrogram(path string, values []float32) error {
+	raw := make([]byte, len(values)*4)
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(value))
+	}
+	return os.WriteFile(path, raw, 0644)
+}
+
+// Largest difference between two spectrograms, and how many values exceed GoldenTolerance
+func Spectrogram_Difference(expected []float32, actual []float32) (largest float64, over int) {
+	for i := range expected {
+		difference := math.Abs(float64(expected[i]) - float64(actual[i]))
+		largest = math.Max(largest, difference)
+		if difference > GoldenTolerance {
+			over++
+		}
+	}
+	return largest, over
+}
+
+// Primary post-bootstrap entry point
+// Print (or save) the spectrogram of a tagged (.dat) clip, for comparison with Python
+// Tagged clips (and golden fixtures) use Default_DSP, whatever the capture sample rate.
+func DSP_Check(input_path string, output_path string, json_output bool) {
+	if input_path == "" {
+		log.Die("No input path provided (-i)")
+	}
+	pcmData, err := os.ReadFile(input_path)
+	if err != nil {
+		log.Die("Unable to read input: %s", err)
+	}
+	dsp := Default_DSP
+	output := make([]float32, dsp.Nmels*dsp.Frames)
+	if err := New_Preparer(dsp).Spectrogram(pcmData, output); err != nil {
+		log.Die("Unable to prepare %s: %s", input_path, err)
+	}
+
+	// Compare against a golden spectrogram saved next to the input
+	golden := strings.TrimSuffix(input_path, filepath.Ext(input_path)) + ".mel"
+	if expected, err := Read_Spectrogram(golden, dsp); err == nil {
+		largest, over := Spectrogram_Difference(expected, output)
+		log.Info("Compared with %s: largest difference %.6f, %d values over %.4f",
+			golden, largest, over, GoldenTolerance)
+	} else if !os.IsNotExist(err) {
+		log.Warn("Unable to compare with %s: %s", golden, err)
+	}
+
+	switch {
+	case output_path != "":
+		if err := Write_Spectrogram(output_path, output); err != nil {
+			log.Die("Unable to save %s: %s", output_path, err)
+		}
+		log.Info("Saved spectrogram to %s", output_path)
+	case json_output:
+		rows := make([][]float32, dsp.Nmels)
+		for m := range rows {
+			rows[m] = output[m*dsp.Frames : (m+1)*dsp.Frames]
+		}
+		json.NewEncoder(os.Stdout).Encode(rows)
+	default:
+		// One line per mel bin (readable by numpy.loadtxt)
+		for m := 0; m < dsp.Nmels; m++ {
+			values := make([]string, dsp.Frames)
+			for t := range values {
+				values[t] = fmt.Sprintf("%.6f", output[m*dsp.Frames+t])
+			}
+			fmt.Println(strings.Join(values, " "))
+		}
+	}
+}
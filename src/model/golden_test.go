@@ -0,0 +1,81 @@
+package model_test
+
+import (
+	// DTrack
+	"dtrack/model"
+
+	// Standard
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Go spectrograms match the training DSP (ai/model.py) for every test clip
+// Golden spectrograms are generated with: python3 -m ai.golden src/model/test_*.dat
+// Test clips are recorded at the default sample rate.
+func TestPrepare_Golden(t *testing.T) {
+	inputs, err := filepath.Glob("test_*.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("No test clips (test_*.dat) found")
+	}
+
+	preparer := model.New_Preparer(model.Default_DSP)
+	for _, input := range inputs {
+		golden := strings.TrimSuffix(input, ".dat") + ".mel"
+		expected, err := model.Read_Spectrogram(golden, model.Default_DSP)
+		if os.IsNotExist(err) {
+			t.Errorf("%s: missing golden spectrogram (python3 -m ai.golden src/model/test_*.dat)", golden)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", golden, err)
+		}
+		pcmData, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatalf("Could not read audio file: %v", err)
+		}
+
+		actual := make([]float32, model.Nmels*model.SpectrogramFrames)
+		if err := preparer.Spectrogram(pcmData, actual); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if largest, over := model.Spectrogram_Difference(expected, actual); over > 0 {
+			t.Errorf("%s: %d values differ by more than %.4f (largest %.6f)",
+				input, over, model.GoldenTolerance, largest)
+		}
+	}
+}
+
+// Spectrograms survive a save and load unchanged
+func TestWrite_Spectrogram(t *testing.T) {
+	prepared, err := model.Prepare(make([]byte, model.Sample_Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := prepared.Data().([]float32)
+	values[1] = 0.5
+
+	path := filepath.Join(t.TempDir(), "silence.mel")
+	if err := model.Write_Spectrogram(path, values); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := model.Read_Spectrogram(path, model.Recording_DSP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if largest, over := model.Spectrogram_Difference(values, loaded); largest != 0 || over != 0 {
+		t.Errorf("Loaded spectrogram differs by %.6f", largest)
+	}
+
+	// Wrong sizes (e.g. another DSP configuration) are rejected
+	if err := os.WriteFile(path, []byte{1, 2, 3, 4}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Read_Spectrogram(path, model.Recording_DSP()); err == nil {
+		t.Error("Expected error for truncated spectrogram")
+	}
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+
+	// Standard
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Spectrogram settings a model was trained with
+type DSP struct {
+	Sample_Rate  int `json:"sample_rate"`
+	Segment_Size int `json:"segment_size"`
+	Nmels        int `json:"n_mels"`
+	Nfft         int `json:"n_fft"`
+	Hop_Length   int `json:"hop_length"`
+	Frames       int `json:"frames"`
+}
+
+// Settings of models saved without metadata (always recorded at 48000 Hz)
+var Default_DSP = DSP{
+	Sample_Rate:  ffmpeg.DefaultSampleRate,
+	Segment_Size: SegmentSize,
+	Nmels:        Nmels,
+	Nfft:         Nfft,
+	Hop_Length:   HopLength,
+	Frames:       SpectrogramFrames,
+}
+
+// Default settings, at the configured sample rate
+// Matches the spectrograms ai/model.py makes for the same configuration.
+func Recording_DSP() DSP {
+	dsp := Default_DSP
+	dsp.Sample_Rate = ffmpeg.Sample_Rate()
+	dsp.Frames = 1 + dsp.samples()/dsp.Hop_Length
+	return dsp
+}
+
+// Contents of a model's .labels file
+// Older models only saved the list of labels, and use Default_DSP.
+type Metadata struct {
+	Labels  []string `json:"labels"`
+	DSP     DSP      `json:"dsp"`
+	Version int      `json:"version"`
+	Trained string   `json:"trained"`
+}
+
+// Parse a .labels file: a list of labels, or an object with metadata
+func Parse_Metadata(data []byte) (Metadata, error) {
+	metadata := Metadata{DSP: Default_DSP}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &metadata.Labels); err != nil {
+			return metadata, err
+		}
+		return metadata, metadata.DSP.Validate()
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return metadata, err
+	}
+	if len(metadata.Labels) == 0 {
+		return metadata, fmt.Errorf("no labels")
+	}
+	return metadata, metadata.DSP.Validate()
+}
+
+// Check that windows can be prepared with these settings
+// Check windows are always SegmentSize seconds, captured at ffmpeg.Sample_Rate().
+func (d DSP) Validate() error {
+	switch {
+	case d.Sample_Rate != ffmpeg.Sample_Rate():
+		return fmt.Errorf("model expects %d Hz audio; recording at %d Hz", d.Sample_Rate, ffmpeg.Sample_Rate())
+	case d.Segment_Size != SegmentSize:
+		return fmt.Errorf("model expects %d second windows; inspecting %d seconds", d.Segment_Size, SegmentSize)
+	case d.Nfft < 4 || d.Nfft&(d.Nfft-1) != 0:
+		return fmt.Errorf("n_fft must be a power of 2 (got %d)", d.Nfft)
+	case d.Nfft > d.samples():
+		return fmt.Errorf("n_fft (%d) is longer than a window", d.Nfft)
+	case d.Hop_Length <= 0 || d.Nmels <= 0 || d.Frames <= 0:
+		return fmt.Errorf("hop_length, n_mels, and frames must be positive")
+	}
+	return nil
+}
+
+// Samples in one check window
+func (d DSP) samples() int {
+	return d.Sample_Rate * d.Segment_Size
+}
+
+// Bytes of raw PCM in one check window
+func (d DSP) window_size() int {
+	return d.samples() * ffmpeg.SampleBytes
+}
+
+// Frames calculated from a check window; the rest of Frames is padding
+func (d DSP) calculated_frames() int {
+	return min((d.samples()-d.Nfft)/d.Hop_Length+1, d.Frames)
+}
@@ -0,0 +1,103 @@
+package model_test
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+	"dtrack/model"
+	"dtrack/state"
+
+	// Standard
+	"slices"
+	"testing"
+)
+
+// Plain label lists (older models) use the default DSP settings
+func TestParse_Metadata_Legacy(t *testing.T) {
+	metadata, err := model.Parse_Metadata([]byte(`["dog", "empty"]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(metadata.Labels, []string{"dog", "empty"}) || metadata.DSP != model.Default_DSP {
+		t.Errorf("Unexpected metadata: %+v", metadata)
+	}
+}
+
+// Metadata overrides only the DSP settings it names
+func TestParse_Metadata(t *testing.T) {
+	metadata, err := model.Parse_Metadata([]byte(`{
+		"labels": ["dog", "empty"],
+		"version": 3,
+		"trained": "2026-01-02T03:04:05",
+		"dsp": {"n_mels": 64, "hop_length": 1024, "frames": 94}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := model.Default_DSP
+	expected.Nmels, expected.Hop_Length, expected.Frames = 64, 1024, 94
+	if metadata.DSP != expected || metadata.Version != 3 || metadata.Trained == "" {
+		t.Errorf("Unexpected metadata: %+v", metadata)
+	}
+
+	// Models that cannot be prepared for are rejected
+	for _, labels := range []string{
+		`{"labels": ["dog"], "dsp": {"sample_rate": 16000}}`,
+		`{"labels": ["dog"], "dsp": {"n_fft": 1000}}`,
+		`{"dsp": {}}`,
+	} {
+		if _, err := model.Parse_Metadata([]byte(labels)); err == nil {
+			t.Errorf("Expected error for %s", labels)
+		}
+	}
+}
+
+// Windows are prepared with the settings of each model
+func TestWindow_Prepare(t *testing.T) {
+	small := model.Default_DSP
+	small.Nmels, small.Hop_Length, small.Frames = 64, 1024, 94
+
+	window := model.New_Window(make([]byte, model.Sample_Size()))
+	prepared, err := window.Prepare(small)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(prepared.Shape(), []int{1, 1, 64, 94}) {
+		t.Errorf("Expected shape [1 1 64 94], got %v", prepared.Shape())
+	}
+
+	// Each setting is only prepared once
+	again, _ := window.Prepare(small)
+	standard, _ := window.Prepare(model.Default_DSP)
+	if again != prepared || standard == prepared {
+		t.Error("Expected one prepared tensor per DSP setting")
+	}
+}
+
+// Models are validated against the configured sample rate
+func TestRecording_DSP(t *testing.T) {
+	defer func() { state.Runtime = state.Application_Configuration{} }()
+	if model.Recording_DSP() != model.Default_DSP {
+		t.Errorf("Expected default settings at %d Hz, got %+v", ffmpeg.DefaultSampleRate, model.Recording_DSP())
+	}
+
+	state.Runtime.Record_Audio_Rate = 16000
+	dsp := model.Recording_DSP()
+	if dsp.Sample_Rate != 16000 || dsp.Frames != 63 {
+		t.Errorf("Expected 63 frames at 16000 Hz, got %+v", dsp)
+	}
+	if _, err := model.Parse_Metadata([]byte(`{"labels": ["dog"], "dsp": {"sample_rate": 16000, "frames": 63}}`)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Older models were trained at 48000 Hz
+	if _, err := model.Parse_Metadata([]byte(`["dog", "empty"]`)); err == nil {
+		t.Error("Expected error for a 48000 Hz model recording at 16000 Hz")
+	}
+	prepared, err := model.Prepare(make([]byte, model.Sample_Size()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(prepared.Shape(), []int{1, 1, model.Nmels, 63}) {
+		t.Errorf("Expected shape [1 1 %d 63], got %v", model.Nmels, prepared.Shape())
+	}
+}
@@ -6,15 +6,16 @@ import (
 	"dtrack/ffmpeg"
 
 	// Standard
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"math"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	// 3rd-Party
-	"github.com/mjibson/go-dsp/fft"
-	"github.com/mjibson/go-dsp/window"
 	"github.com/owulveryck/onnx-go"
 	"github.com/owulveryck/onnx-go/backend/x/gorgonnx"
 	"gorgonia.org/tensor"
@@ -24,9 +25,6 @@ const (
 	// Number of seconds in each scanned segment
 	SegmentSize       = 2
 
-	// Full size of "check window"
-	SampleSize        = ffmpeg.BytesPerSecond * SegmentSize
-
 	// Spectrogram Values
 	Nmels             = 128
 	Nfft              = 2048
@@ -34,15 +32,38 @@ const (
 	SpectrogramFrames = 188
 	Int16Max          = 32768.0
 
-	// Frequency Limits
-	MaxFreq = float64(ffmpeg.SampleRate) / 2
+	// Frequency Limits (the highest is half of the sample rate)
 	MinFreq = 0.0
+
+	// Windows inferred at once by a scanner that fell behind (see Infer_Batch)
+	BatchMax = 4
 )
 
-// OnnxModel holds raw bytes AND the class labels.
+// Full size of "check window", at the configured sample rate
+func Sample_Size() int {
+	return ffmpeg.Bytes_Per_Second() * SegmentSize
+}
+
+// OnnxModel holds raw bytes, the class labels, AND the parsed graph.
 type OnnxModel struct {
 	RawBytes []byte
+	Path     string // File the model was loaded from
 	Labels   []string
+	Hash     string // SHA-256 of the graph and labels
+	DSP      DSP    // Spectrogram settings used for training
+	Version  int    // Training run, counted by ai/train.py (0 if unknown)
+	Trained  string // Training date (empty if unknown)
+
+	// Parsed once by Load(); reused (one inference at a time) by Infer()
+	lock    sync.Mutex
+	backend *gorgonnx.Graph
+	graph   *onnx.Model
+
+	// Gorgonia fixes input shapes on the first run, so batches of BatchMax
+	// windows use a second graph, parsed by the first batch.
+	batch_backend *gorgonnx.Graph
+	batch_graph   *onnx.Model
+	unbatched     bool // Batch inference failed once; only run single windows
 }
 
 // Not supported by golang
@@ -50,14 +71,15 @@ func Train() {
 	log.Info("Model Training is handled by python -m ai.train")
 }
 
-// Load initializes the model bytes and loads the labels.json file.
-func Load(model_path string) OnnxModel {
+// Load initializes the model graph and loads the labels.json file.
+// Failures are returned as *Error, for the caller to decide what to stop.
+func Load(model_path string) (*OnnxModel, error) {
 	log.Debug("Loading model from %s", model_path)
 
 	// Read .onnx file
 	bytes, err := os.ReadFile(model_path)
 	if err != nil {
-		log.Die("could not read ONNX file: %s", err)
+		return nil, &Error{Path: model_path, Kind: ErrModelFile, Err: err}
 	}
 
 	// Read json labels file (e.g. whistle.onnx -> whistle.labels)
@@ -66,166 +88,239 @@ func Load(model_path string) OnnxModel {
 
 	labelsBytes, err := os.ReadFile(jsonPath)
 	if err != nil {
-		log.Die("could not read Labels file: %s", err)
+		return nil, &Error{Path: model_path, Kind: ErrLabels, Err: err}
+	}
+
+	metadata, err := Parse_Metadata(labelsBytes)
+	if err != nil {
+		return nil, &Error{Path: model_path, Kind: ErrLabels, Err: err}
 	}
 
-	var labels []string
-	if err := json.Unmarshal(labelsBytes, &labels); err != nil {
-		log.Die("could not parse Labels JSON: %s", err)
+	// Build the graph once; Infer() only swaps the input tensor
+	backend := gorgonnx.NewGraph()
+	graph := onnx.NewModel(backend)
+	if err := unmarshal_graph(graph, bytes); err != nil {
+		return nil, &Error{Path: model_path, Kind: ErrGraph, Err: err}
 	}
 
-	log.Debug("Loaded %s with classes: %v", model_path, labels)
+	log.Debug("Loaded %s (version %d) with classes: %v", model_path, metadata.Version, metadata.Labels)
 
-	return OnnxModel{
+	// Fingerprint covers both the graph and its labels
+	hash := sha256.New()
+	hash.Write(bytes)
+	hash.Write(labelsBytes)
+
+	return &OnnxModel{
 		RawBytes: bytes,
-		Labels:   labels,
-	}
+		Path:     model_path,
+		Labels:   metadata.Labels,
+		Hash:     hex.EncodeToString(hash.Sum(nil)),
+		DSP:      metadata.DSP,
+		Version:  metadata.Version,
+		Trained:  metadata.Trained,
+		backend:  backend,
+		graph:    graph,
+	}, nil
 }
 
-// Prepare raw audio bytes and convert them to a ready-to-infer tensor (DSP logic)
-func Prepare(pcmData []byte) (*tensor.Dense, error) {
-	// 1. Pad/Truncate the data to ensure fixed length (SampleSize)
-	if len(pcmData) < SampleSize {
-		log.Warn("Audio Underflow; Segment was not large enough!")
-		padding := make([]byte, SampleSize-len(pcmData))
-		pcmData = append(pcmData, padding...)
+// Parse an ONNX graph; broken files may panic inside onnx-go
+func unmarshal_graph(graph *onnx.Model, bytes []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return graph.UnmarshalBinary(bytes)
+}
+
+// Check runs a silent test window through a model, reporting any problem.
+func Check(checkModel *OnnxModel) error {
+	preparedAudio, err := Prepare_DSP(checkModel.DSP, make([]byte, checkModel.DSP.window_size()))
+	if err != nil {
+		return model_error(checkModel, ErrInput, "%s", err)
 	}
-	if len(pcmData) > SampleSize {
-		log.Warn("Audio Overflow; Segment was too large!")
-		pcmData = pcmData[:SampleSize]
+	probs, err := run(checkModel, preparedAudio)
+	if err != nil {
+		return err
 	}
-
-	// 2. Normalize to float64 for DSP
-	audioFloat32 := normalizeAudio(pcmData)
-	audioFloat64 := make([]float64, len(audioFloat32))
-	for i, v := range audioFloat32 {
-		audioFloat64[i] = float64(v)
+	if len(probs) != len(checkModel.Labels) {
+		return model_error(checkModel, ErrOutput, "%d outputs for %d labels", len(probs), len(checkModel.Labels))
 	}
+	return nil
+}
 
-	// 3. STFT Calculation
-	n_frames_calculated := (len(audioFloat64)-Nfft)/HopLength + 1
+// Infer runs the model and returns a MAP of probabilities (Multi-Class).
+// Returns: map["barking"] = 0.8, map["empty"] = 0.2
+func Infer(inferModel *OnnxModel, preparedAudio *tensor.Dense) (map[string]float64, error) {
+	probs, err := run(inferModel, preparedAudio)
+	if err != nil {
+		return nil, err
+	}
+	return label(inferModel, probs), nil
+}
 
-	// Check for extreme case where calculated frames exceed expected
-	if n_frames_calculated > SpectrogramFrames {
-		log.Warn("ML: Calculated frames exceed expected. Using calculated size.")
-		n_frames_calculated = SpectrogramFrames
+// Infer_Batch runs prepared windows in batches of BatchMax (along the batch dimension).
+// Remaining windows, and every window of a model that cannot run a batch, are inferred one at a time.
+func Infer_Batch(inferModel *OnnxModel, preparedAudio []*tensor.Dense) ([]map[string]float64, error) {
+	results := make([]map[string]float64, 0, len(preparedAudio))
+	for len(preparedAudio)-len(results) >= BatchMax {
+		batch, err := run_batch(inferModel, preparedAudio[len(results):len(results)+BatchMax])
+		if err != nil {
+			if err != errUnbatched {
+				log.Debug("Batch inference failed (%s); inferring one window at a time", err)
+			}
+			break
+		}
+		for _, probs := range batch {
+			results = append(results, label(inferModel, probs))
+		}
 	}
 
-	// Calculate bins to keep based on Full Spectrum (Matches Python)
-	bins_linear := Nfft/2 + 1 // 1025 bins
+	for _, window := range preparedAudio[len(results):] {
+		result, err := Infer(inferModel, window)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
 
-	// Create Power Spectrogram (bins_linear * frames)
-	powerSpectrogram := make([][]float64, bins_linear)
-	for i := range powerSpectrogram {
-		powerSpectrogram[i] = make([]float64, n_frames_calculated)
+// Map probabilities to labels
+func label(inferModel *OnnxModel, probs []float64) map[string]float64 {
+	results := make(map[string]float64)
+	for i, label := range inferModel.Labels {
+		if i < len(probs) {
+			results[label] = probs[i]
+		}
 	}
+	return results
+}
 
-	window_func := window.Hann(Nfft)
+// Returned by run_batch once a model failed to run a batch
+var errUnbatched = fmt.Errorf("model does not support batches")
 
-	for i := 0; i < n_frames_calculated; i++ {
-		start := i * HopLength
-		end := start + Nfft
-		if end > len(audioFloat64) {
-			end = len(audioFloat64)
-		}
+// Run inference, returning the probability of each model output
+func run(inferModel *OnnxModel, preparedAudio *tensor.Dense) ([]float64, error) {
+	// Graph holds per-run state; one inference at a time
+	inferModel.lock.Lock()
+	defer inferModel.lock.Unlock()
 
-		segment := audioFloat64[start:end]
-		windowed := make([]float64, Nfft)
-		for j := 0; j < len(segment); j++ {
-			windowed[j] = segment[j] * window_func[j]
-		}
+	logits, err := run_locked(inferModel, inferModel.backend, inferModel.graph, preparedAudio)
+	if err != nil {
+		return nil, err
+	}
 
-		complex_input := make([]complex128, Nfft)
-		for j := 0; j < Nfft; j++ {
-			complex_input[j] = complex(windowed[j], 0)
-		}
-		complex_result := fft.FFT(complex_input)
+	// Convert Logits to Probabilities (Softmax)
+	return softmax(logits), nil
+}
 
-		// Magnitude Square
-		for j := 0; j < bins_linear; j++ {
-			val := complex_result[j]
-			magSq := real(val)*real(val) + imag(val)*imag(val)
-			powerSpectrogram[j][i] = magSq
+// Run inference on windows stacked along the batch dimension
+// Returns the probabilities of each window, in order.
+func run_batch(inferModel *OnnxModel, preparedAudio []*tensor.Dense) ([][]float64, error) {
+	inferModel.lock.Lock()
+	defer inferModel.lock.Unlock()
+	if inferModel.unbatched {
+		return nil, errUnbatched
+	}
+	if inferModel.batch_graph == nil {
+		backend := gorgonnx.NewGraph()
+		graph := onnx.NewModel(backend)
+		if err := unmarshal_graph(graph, inferModel.RawBytes); err != nil {
+			inferModel.unbatched = true
+			return nil, model_error(inferModel, ErrGraph, "%s", err)
 		}
+		inferModel.batch_backend, inferModel.batch_graph = backend, graph
 	}
 
-	// 4. Convert to Mel Spectrogram
-	melSpectrogram := applyMelFilterbank(powerSpectrogram, bins_linear, n_frames_calculated)
-	melDb := powerToDb(melSpectrogram)
-
-	// 5. Normalize for tensor (-80db floor)
-	normalized := fixedNormalize(melDb)
-	flatData := make([]float32, Nmels * SpectrogramFrames)
-
-	idx := 0
-	for r := 0; r < Nmels; r++ {
-		for c := 0; c < SpectrogramFrames; c++ {
-			if c < n_frames_calculated {
-				flatData[idx] = float32(normalized[r][c])
-			} else {
-				//log.Warn("Padding used during data normalization")
-				flatData[idx] = 0.0
-			}
-			idx++
+	// Stack [1, 1, Nmels, Frames] windows into [N, 1, Nmels, Frames]
+	dsp := inferModel.DSP
+	size := dsp.Nmels * dsp.Frames
+	stacked := make([]float32, 0, len(preparedAudio)*size)
+	for _, window := range preparedAudio {
+		data, ok := window.Data().([]float32)
+		if !ok || len(data) != size {
+			return nil, model_error(inferModel, ErrInput, "expected %d values", size)
 		}
+		stacked = append(stacked, data...)
 	}
-
-	// Final tensor shape: [Batch, Channel, Height, Width]
-	shape := []int{1, 1, Nmels, SpectrogramFrames}
-
-
-	inputTensor := tensor.New(
+	input := tensor.New(
 		tensor.Of(tensor.Float32),
-		tensor.WithShape(shape...),
-		tensor.WithBacking(flatData),
+		tensor.WithShape(len(preparedAudio), 1, dsp.Nmels, dsp.Frames),
+		tensor.WithBacking(stacked),
 	)
 
-	return inputTensor, nil
-}
-
-// Infer runs the model and returns a MAP of probabilities (Multi-Class).
-// Returns: map["barking"] = 0.8, map["empty"] = 0.2
-func Infer(inferModel OnnxModel, preparedAudio *tensor.Dense) map[string]float64 {
-	// Create Backend
-	backend := gorgonnx.NewGraph()
-	onnxModel := onnx.NewModel(backend)
+	// Models exported with a fixed batch size fail (or return a single row)
+	logits, err := run_locked(inferModel, inferModel.batch_backend, inferModel.batch_graph, input)
+	if err == nil && len(logits) != len(preparedAudio)*len(inferModel.Labels) {
+		err = model_error(inferModel, ErrOutput, "%d outputs for a batch of %d", len(logits), len(preparedAudio))
+	}
+	if err != nil {
+		// The batch graph is never used again
+		inferModel.unbatched = true
+		inferModel.batch_backend, inferModel.batch_graph = nil, nil
+		return nil, err
+	}
 
-	// Unmarshal
-	if err := onnxModel.UnmarshalBinary(inferModel.RawBytes); err != nil {
-		log.Die("could not unmarshal ONNX model: %s", err)
+	batch := make([][]float64, len(preparedAudio))
+	classes := len(inferModel.Labels)
+	for i := range batch {
+		batch[i] = softmax(logits[i*classes : (i+1)*classes])
 	}
+	return batch, nil
+}
+
+// Run a graph of the model, returning raw output logits; inferModel.lock must be held
+func run_locked(inferModel *OnnxModel, backend *gorgonnx.Graph, graph *onnx.Model,
+	preparedAudio *tensor.Dense) (logits []float64, err error) {
+	// Mismatched graphs (e.g. wrong input shape) panic inside gorgonia
+	defer func() {
+		if r := recover(); r != nil {
+			err = model_error(inferModel, ErrInference, "%v", r)
+		}
+	}()
 
 	// Run Inference
-	onnxModel.SetInput(0, tensor.Tensor(preparedAudio))
+	if err := graph.SetInput(0, tensor.Tensor(preparedAudio)); err != nil {
+		return nil, model_error(inferModel, ErrInput, "%s", err)
+	}
 	if err := backend.Run(); err != nil {
-		log.Die("Inference failed: %v", err)
+		return nil, model_error(inferModel, ErrInference, "%s", err)
 	}
 
 	// Get Output
-	outputTensors, _ := onnxModel.GetOutputTensors()
+	outputTensors, _ := graph.GetOutputTensors()
+	if len(outputTensors) == 0 {
+		return nil, model_error(inferModel, ErrOutput, "no output tensor")
+	}
 	outputDense, ok := outputTensors[0].(*tensor.Dense)
 	if !ok {
-		log.Die("Output tensor is not a *tensor.Dense type.")
+		return nil, model_error(inferModel, ErrOutput, "output tensor is not a *tensor.Dense type")
 	}
 
-	// Convert Logits to Probabilities (Softmax)
-	floatSlice := outputDense.Data().([]float32) // Gorgonia usually returns float32
-	logits := make([]float64, len(floatSlice))
+	// Copied out of the graph; output memory is reused by the next run
+	floatSlice, ok := outputDense.Data().([]float32) // Gorgonia usually returns float32
+	if !ok {
+		return nil, model_error(inferModel, ErrOutput, "output tensor is not float32")
+	}
+	logits = make([]float64, len(floatSlice))
 	for i, v := range floatSlice {
 		logits[i] = float64(v)
 	}
+	return logits, nil
+}
 
-	probs := softmax(logits)
-
-	// Map to Labels
-	results := make(map[string]float64)
-	for i, label := range inferModel.Labels {
-		if i < len(probs) {
-			results[label] = probs[i]
+// Find the class with the highest probability
+func Best_Match(predictions map[string]float64) (string, float64) {
+	bestClass := ""
+	bestConf := 0.0
+	for label, score := range predictions {
+		if score > bestConf {
+			bestConf = score
+			bestClass = label
 		}
 	}
-
-	return results
+	return bestClass, bestConf
 }
 
 // Convert logits to probabilities that sum to 1
@@ -252,14 +347,3 @@ func softmax(logits []float64) []float64 {
 	}
 	return exps
 }
-
-// Convert raw 16-bit PCM bytes into a normalized float32 slice
-func normalizeAudio(pcmData []byte) []float32 {
-	numSamples := len(pcmData) / 2
-	audioArray := make([]float32, numSamples)
-	for i := 0; i < numSamples; i++ {
-		val := int16(uint16(pcmData[i*2]) | uint16(pcmData[i*2+1])<<8)
-		audioArray[i] = float32(val) / Int16Max
-	}
-	return audioArray
-}
@@ -5,17 +5,24 @@ import (
 	"dtrack/model"
 
 	// Standard
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	// 3rd-Party
+	"gorgonia.org/tensor"
 )
 
 // Helper function to create a dummy raw audio buffer for dimensions testing
 func createTestAudioBuffer(fillData bool) []byte {
-	buffer := make([]byte, model.SampleSize)
+	buffer := make([]byte, model.Sample_Size())
 	if fillData {
-		for i := 0; i < model.SampleSize; i++ {
+		for i := 0; i < model.Sample_Size(); i++ {
 			buffer[i] = byte(i % 256)
 		}
 	}
@@ -91,7 +98,10 @@ func testMultiClass_Inference(t *testing.T, audioPath string, expectedResult str
 	}
 
 	// Load Model (This will also load the JSON labels)
-	myModel := model.Load(onnxPath)
+	myModel, err := model.Load(onnxPath)
+	if err != nil {
+		t.Fatalf("Could not load model: %v", err)
+	}
 
 	if len(myModel.Labels) < 2 {
 		t.Errorf("Labels not loaded correctly. Found: %v", myModel.Labels)
@@ -111,7 +121,10 @@ func testMultiClass_Inference(t *testing.T, audioPath string, expectedResult str
 	}
 
 	// Infer (Returns Map[string]float64)
-	results := model.Infer(myModel, preparedTensor)
+	results, err := model.Infer(myModel, preparedTensor)
+	if err != nil {
+		t.Fatalf("Inference failed: %v", err)
+	}
 
 	// Check if map is empty
 	if len(results) == 0 {
@@ -145,3 +158,263 @@ func testMultiClass_Inference(t *testing.T, audioPath string, expectedResult str
 		t.Errorf("Expected %s, but found %s", expectedResult, best_class)
 	}
 }
+
+// Batched inference matches one window at a time, in order
+func TestInfer_Batch(t *testing.T) {
+	// Average level of the window, times 4 (dog) and -4 (empty)
+	myModel := writeTestModel(t,
+		onnxNode("GlobalAveragePool", "pooled", "input"),
+		onnxNode("Flatten", "flat", "pooled"),
+		onnxNode("MatMul", "output", "flat", "weight"),
+	)
+	checkBatch(t, myModel, model.BatchMax+2)
+}
+
+// Models exported with a fixed batch of 1 infer one window at a time
+func TestInfer_Unbatched(t *testing.T) {
+	myModel := writeTestModel(t,
+		onnxNode("GlobalAveragePool", "pooled", "input"),
+		onnxNode("Reshape", "flat", "pooled", "shape"),
+		onnxNode("MatMul", "output", "flat", "weight"),
+	)
+	checkBatch(t, myModel, model.BatchMax)
+
+	// Later batches skip straight to single windows
+	checkBatch(t, myModel, model.BatchMax)
+}
+
+// Infer windows of increasing level in a batch, and compare to single windows
+func checkBatch(t *testing.T, myModel *model.OnnxModel, windows int) {
+	t.Helper()
+	dsp := myModel.DSP
+	prepared := []*tensor.Dense{}
+	for i := 0; i < windows; i++ {
+		data := make([]float32, dsp.Nmels*dsp.Frames)
+		for j := range data {
+			data[j] = float32(i) / float32(windows)
+		}
+		prepared = append(prepared, tensor.New(
+			tensor.Of(tensor.Float32),
+			tensor.WithShape(1, 1, dsp.Nmels, dsp.Frames),
+			tensor.WithBacking(data),
+		))
+	}
+
+	batch, err := model.Infer_Batch(myModel, prepared)
+	if err != nil {
+		t.Fatalf("Batch inference failed: %v", err)
+	}
+	if len(batch) != len(prepared) {
+		t.Fatalf("Expected %d results, got %d", len(prepared), len(batch))
+	}
+	for i, window := range prepared {
+		level := float64(i) / float64(windows)
+		expected := 1 / (1 + math.Exp(-8*level))
+		if math.Abs(batch[i]["dog"]-expected) > 1e-4 {
+			t.Errorf("Window %d: dog expected %.4f, got %.4f", i, expected, batch[i]["dog"])
+		}
+		single, err := model.Infer(myModel, window)
+		if err != nil {
+			t.Fatalf("Inference failed: %v", err)
+		}
+		for label, probability := range single {
+			if math.Abs(batch[i][label]-probability) > 1e-4 {
+				t.Errorf("Window %d: %s expected %.4f, got %.4f", i, label, probability, batch[i][label])
+			}
+		}
+	}
+}
+
+// Write a dog/empty model with the given nodes, taking "input" (any batch) to "output"
+func writeTestModel(t *testing.T, nodes ...[]byte) *model.OnnxModel {
+	t.Helper()
+	dsp := model.Default_DSP
+	graph := onnxCat(nodes...)
+	graph = onnxCat(graph,
+		onnxBytes(2, []byte("test")),
+		onnxBytes(5, onnxFloats("weight", []int64{1, 2}, []float32{4, -4})),
+		onnxBytes(5, onnxInts("shape", []int64{1, 1})),
+		onnxBytes(11, onnxValueInfo("input", -1, 1, int64(dsp.Nmels), int64(dsp.Frames))),
+		onnxBytes(12, onnxValueInfo("output", -1, 2)),
+	)
+	// IR version 7, opset 13
+	proto := onnxCat(onnxVarint(1, 7), onnxBytes(8, onnxVarint(2, 13)), onnxBytes(7, graph))
+
+	path := filepath.Join(t.TempDir(), "test.onnx")
+	if err := os.WriteFile(path, proto, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(strings.TrimSuffix(path, ".onnx")+".labels", []byte(`["dog", "empty"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	myModel, err := model.Load(path)
+	if err != nil {
+		t.Fatalf("Could not load model: %v", err)
+	}
+	return myModel
+}
+
+// Protocol buffer encoding, just enough for writeTestModel
+func onnxKey(field int, wire int) []byte {
+	return binary.AppendUvarint(nil, uint64(field<<3|wire))
+}
+
+func onnxVarint(field int, value int64) []byte {
+	return append(onnxKey(field, 0), binary.AppendUvarint(nil, uint64(value))...)
+}
+
+func onnxBytes(field int, data []byte) []byte {
+	return onnxCat(onnxKey(field, 2), binary.AppendUvarint(nil, uint64(len(data))), data)
+}
+
+func onnxCat(parts ...[]byte) []byte {
+	joined := []byte{}
+	for _, part := range parts {
+		joined = append(joined, part...)
+	}
+	return joined
+}
+
+// NodeProto: inputs, output, name and op_type
+func onnxNode(op string, output string, inputs ...string) []byte {
+	node := []byte{}
+	for _, input := range inputs {
+		node = append(node, onnxBytes(1, []byte(input))...)
+	}
+	return onnxBytes(1, onnxCat(node, onnxBytes(2, []byte(output)), onnxBytes(3, []byte(output)), onnxBytes(4, []byte(op))))
+}
+
+// ValueInfoProto for a float tensor; negative dimensions are the (named) batch size
+func onnxValueInfo(name string, dims ...int64) []byte {
+	shape := []byte{}
+	for _, dim := range dims {
+		if dim < 0 {
+			shape = append(shape, onnxBytes(1, onnxBytes(2, []byte("batch")))...)
+		} else {
+			shape = append(shape, onnxBytes(1, onnxVarint(1, dim))...)
+		}
+	}
+	tensorType := onnxCat(onnxVarint(1, 1), onnxBytes(2, shape))
+	return onnxCat(onnxBytes(1, []byte(name)), onnxBytes(2, onnxBytes(1, tensorType)))
+}
+
+// TensorProto initializers (float and int64)
+func onnxFloats(name string, dims []int64, values []float32) []byte {
+	raw := []byte{}
+	for _, value := range values {
+		raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(value))
+	}
+	return onnxTensor(name, 1, dims, raw)
+}
+
+func onnxInts(name string, values []int64) []byte {
+	raw := []byte{}
+	for _, value := range values {
+		raw = binary.LittleEndian.AppendUint64(raw, uint64(value))
+	}
+	return onnxTensor(name, 7, []int64{int64(len(values))}, raw)
+}
+
+func onnxTensor(name string, dataType int64, dims []int64, raw []byte) []byte {
+	tensor := []byte{}
+	for _, dim := range dims {
+		tensor = append(tensor, onnxVarint(1, dim)...)
+	}
+	return onnxCat(tensor, onnxVarint(2, dataType), onnxBytes(8, []byte(name)), onnxBytes(9, raw))
+}
+
+// Load the test model and a prepared sample, or skip when unavailable
+func benchmarkSetup(b *testing.B) (*model.OnnxModel, []byte) {
+	for _, path := range []string{"test_model.onnx", "test_model.labels", "test_bigdog.dat"} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			b.Skipf("Skipping Benchmark: %s not found.", path)
+		}
+	}
+	rawBytes, err := os.ReadFile("test_bigdog.dat")
+	if err != nil {
+		b.Fatalf("Could not read audio file: %v", err)
+	}
+	myModel, err := model.Load("test_model.onnx")
+	if err != nil {
+		b.Fatalf("Could not load model: %v", err)
+	}
+	return myModel, rawBytes
+}
+
+// Inference using the graph cached by Load()
+func BenchmarkInfer(b *testing.B) {
+	myModel, rawBytes := benchmarkSetup(b)
+	preparedTensor, _ := model.Prepare(rawBytes)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		model.Infer(myModel, preparedTensor)
+	}
+}
+
+// Inference with the model reloaded (and graph rebuilt) every call
+func BenchmarkInfer_Uncached(b *testing.B) {
+	_, rawBytes := benchmarkSetup(b)
+	preparedTensor, _ := model.Prepare(rawBytes)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		myModel, _ := model.Load("test_model.onnx")
+		model.Infer(myModel, preparedTensor)
+	}
+}
+
+// TestLevel: Silence, full-scale, and half-scale audio report expected dBFS.
+func TestLevel(t *testing.T) {
+	// Digital silence sits at the floor
+	rms, peak := model.Level(createTestAudioBuffer(false))
+	if rms != model.SilenceFloor || peak != model.SilenceFloor {
+		t.Errorf("Silence: expected %.0f dBFS, got RMS %.1f, Peak %.1f", model.SilenceFloor, rms, peak)
+	}
+
+	// Square wave at full scale (-32768/32767)
+	full := make([]byte, model.Sample_Size())
+	for i := 0; i < len(full); i += 4 {
+		full[i], full[i+1] = 0x00, 0x80   // -32768
+		full[i+2], full[i+3] = 0xff, 0x7f // 32767
+	}
+	rms, peak = model.Level(full)
+	if peak < -0.01 || rms < -0.01 {
+		t.Errorf("Full scale: expected ~0 dBFS, got RMS %.2f, Peak %.2f", rms, peak)
+	}
+
+	// Constant half-scale signal (16384) is ~-6 dBFS
+	half := make([]byte, model.Sample_Size())
+	for i := 0; i < len(half); i += 2 {
+		half[i], half[i+1] = 0x00, 0x40
+	}
+	rms, peak = model.Level(half)
+	if peak < -6.03 || peak > -6.01 || rms != peak {
+		t.Errorf("Half scale: expected ~-6.02 dBFS, got RMS %.2f, Peak %.2f", rms, peak)
+	}
+}
+
+// Broken or missing models are reported, not fatal
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.onnx")
+	if _, err := model.Load(missing); !errors.Is(err, model.ErrModelFile) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected missing model file, got %v", err)
+	}
+
+	broken := filepath.Join(dir, "broken.onnx")
+	if err := os.WriteFile(broken, []byte("not a model"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Load(broken); !errors.Is(err, model.ErrLabels) {
+		t.Errorf("Expected missing labels, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.labels"), []byte(`["a", "b"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := model.Load(broken)
+	var failure *model.Error
+	if !errors.As(err, &failure) || failure.Kind != model.ErrGraph || failure.Path != broken {
+		t.Errorf("Expected broken graph in %s, got %v", broken, err)
+	}
+}
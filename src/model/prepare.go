@@ -0,0 +1,293 @@
+package model
+
+import (
+	// DTrack
+	"dtrack/log"
+
+	// Standard
+	"fmt"
+	"math"
+	"sync"
+
+	// 3rd-Party
+	"github.com/mjibson/go-dsp/window"
+	"gorgonia.org/tensor"
+)
+
+// Precalculated values for one DSP setting; Prevents recalculating values for every frame
+type dsp_tables struct {
+	dsp          DSP
+	frames       int           // Frames calculated from a check window
+	bins         int           // Linear frequency bins of each frame (Full Spectrum; Matches Python)
+	hann         []float64     // Window applied to each frame
+	fftTwiddles  []complex128  // e^(-2πik/(Nfft/2)), for the half-size complex FFT
+	realTwiddles []complex128  // e^(-2πik/Nfft), to unpack real frequency bins
+	fftReversed  []int         // Bit-reversed order of the half-size FFT input
+	melWeights   [][]float64   // The weights: [MelBin][LinearBin]
+	melBounds    []filterBound // Filter Bounds: Prevent multiply by zero
+}
+
+var (
+	// Tables and reusable Preparers for each DSP setting in use
+	tables_lock sync.Mutex
+	tables      = make(map[DSP]*dsp_tables)
+	preparers   = make(map[DSP]*sync.Pool)
+)
+
+// Returns (calculating once) the tables for a DSP setting
+func tables_for(dsp DSP) *dsp_tables {
+	tables_lock.Lock()
+	defer tables_lock.Unlock()
+	if cached, ok := tables[dsp]; ok {
+		return cached
+	}
+
+	half := dsp.Nfft / 2
+	t := &dsp_tables{
+		dsp:          dsp,
+		frames:       dsp.calculated_frames(),
+		bins:         half + 1,
+		hann:         window.Hann(dsp.Nfft),
+		fftTwiddles:  make([]complex128, half/2),
+		realTwiddles: make([]complex128, half+1),
+		fftReversed:  make([]int, half),
+	}
+	for k := range t.fftTwiddles {
+		sin, cos := math.Sincos(-2 * math.Pi * float64(k) / float64(half))
+		t.fftTwiddles[k] = complex(cos, sin)
+	}
+	for k := range t.realTwiddles {
+		sin, cos := math.Sincos(-2 * math.Pi * float64(k) / float64(dsp.Nfft))
+		t.realTwiddles[k] = complex(cos, sin)
+	}
+	bits := 0
+	for 1<<bits < half {
+		bits++
+	}
+	for i := range t.fftReversed {
+		for b := 0; b < bits; b++ {
+			t.fftReversed[i] |= (i >> b & 1) << (bits - 1 - b)
+		}
+	}
+	t.melWeights, t.melBounds = mel_filterbank(dsp)
+
+	tables[dsp] = t
+	return t
+}
+
+// Reusable DSP buffers, so preparing a window allocates nothing but its output
+// A Preparer is not safe for concurrent use; each goroutine needs its own.
+type Preparer struct {
+	tables  *dsp_tables
+	samples []float64    // Normalized audio of one check window
+	packed  []complex128 // Windowed frame, packed as Nfft/2 complex values
+	power   []float64    // Power spectrogram: [frame*bins + bin]
+	mel     []float64    // Mel spectrogram: [mel*frames + frame]
+}
+
+// Allocate every buffer needed to prepare a check window with one DSP setting
+func New_Preparer(dsp DSP) *Preparer {
+	t := tables_for(dsp)
+	return &Preparer{
+		tables:  t,
+		samples: make([]float64, dsp.samples()),
+		packed:  make([]complex128, dsp.Nfft/2),
+		power:   make([]float64, t.frames*t.bins),
+		mel:     make([]float64, dsp.Nmels*t.frames),
+	}
+}
+
+// Prepare raw audio bytes and convert them to a ready-to-infer tensor (DSP logic)
+func Prepare(pcmData []byte) (*tensor.Dense, error) {
+	return Prepare_DSP(Recording_DSP(), pcmData)
+}
+
+// Prepare, for a model trained with other DSP settings
+func Prepare_DSP(dsp DSP, pcmData []byte) (*tensor.Dense, error) {
+	pool, preparer := borrow(dsp)
+	defer pool.Put(preparer)
+	return preparer.Prepare(pcmData)
+}
+
+// Borrow a reusable Preparer for a DSP setting; return it with pool.Put
+func borrow(dsp DSP) (*sync.Pool, *Preparer) {
+	tables_lock.Lock()
+	pool, ok := preparers[dsp]
+	if !ok {
+		pool = &sync.Pool{New: func() any { return New_Preparer(dsp) }}
+		preparers[dsp] = pool
+	}
+	tables_lock.Unlock()
+	return pool, pool.Get().(*Preparer)
+}
+
+// Prepare a check window; only the returned tensor is allocated
+func (p *Preparer) Prepare(pcmData []byte) (*tensor.Dense, error) {
+	dsp := p.tables.dsp
+	flatData := make([]float32, dsp.Nmels*dsp.Frames)
+	if err := p.Spectrogram(pcmData, flatData); err != nil {
+		return nil, err
+	}
+
+	// Final tensor shape: [Batch, Channel, Height, Width]
+	return tensor.New(
+		tensor.Of(tensor.Float32),
+		tensor.WithShape(1, 1, dsp.Nmels, dsp.Frames),
+		tensor.WithBacking(flatData),
+	), nil
+}
+
+// Write the normalized mel spectrogram of a check window to output [Nmels*Frames]
+func (p *Preparer) Spectrogram(pcmData []byte, output []float32) error {
+	t := p.tables
+	dsp := t.dsp
+	if len(output) != dsp.Nmels*dsp.Frames {
+		return fmt.Errorf("spectrogram output must hold %d values", dsp.Nmels*dsp.Frames)
+	}
+
+	// 1-2. Normalize, then calculate the power spectrogram
+	p.power_spectrum(pcmData)
+
+	// 3. Convert to Mel Spectrogram, tracking the global max for dB
+	maxVal := 1e-10
+	for m := 0; m < dsp.Nmels; m++ {
+		// Only iterate where the filter is not zero
+		start := t.melBounds[m].start
+		end := min(t.melBounds[m].end, t.bins)
+		for f := 0; f < t.frames; f++ {
+			sum := 0.0
+			frame := p.power[f*t.bins : (f+1)*t.bins]
+			for k := start; k < end; k++ {
+				sum += t.melWeights[m][k] * frame[k]
+			}
+			p.mel[m*t.frames+f] = sum
+			if sum > maxVal {
+				maxVal = sum
+			}
+		}
+	}
+
+	// 4. Convert to dB, then normalize for tensor (-80db floor)
+	for m := 0; m < dsp.Nmels; m++ {
+		for f := 0; f < dsp.Frames; f++ {
+			if f >= t.frames {
+				output[m*dsp.Frames+f] = 0.0
+				continue
+			}
+			val := p.mel[m*t.frames+f]
+			if val < 1e-10 {
+				val = 1e-10
+			}
+			db := 10.0 * math.Log10(val/maxVal)
+			if db < -80.0 {
+				db = -80.0
+			}
+			if db > 0.0 {
+				db = 0.0
+			}
+			output[m*dsp.Frames+f] = float32((db + 80.0) / 80.0)
+		}
+	}
+	return nil
+}
+
+// Normalize a check window, then calculate the power of every frame (p.power)
+func (p *Preparer) power_spectrum(pcmData []byte) {
+	t := p.tables
+	dsp := t.dsp
+
+	// 1. Normalize to float64 for DSP; missing samples are silence (padding)
+	if len(pcmData) < dsp.window_size() {
+		log.Warn("Audio Underflow; Segment was not large enough!")
+	}
+	if len(pcmData) > dsp.window_size() {
+		log.Warn("Audio Overflow; Segment was too large!")
+	}
+	for i := range p.samples {
+		val := int16(uint16(pcm_byte(pcmData, i*2)) | uint16(pcm_byte(pcmData, i*2+1))<<8)
+		p.samples[i] = float64(float32(val) / Int16Max)
+	}
+
+	// 2. STFT Calculation (Power Spectrogram)
+	for frame := 0; frame < t.frames; frame++ {
+		p.power_frame(p.samples[frame*dsp.Hop_Length:frame*dsp.Hop_Length+dsp.Nfft],
+			p.power[frame*t.bins:(frame+1)*t.bins])
+	}
+}
+
+// Byte of raw PCM, or zero (padding) past the end
+func pcm_byte(pcmData []byte, index int) byte {
+	if index < len(pcmData) {
+		return pcmData[index]
+	}
+	return 0
+}
+
+// Power of each linear frequency bin of a single (Nfft sample) frame
+// Real input is packed into a half-size complex FFT, then unpacked.
+func (p *Preparer) power_frame(samples []float64, power []float64) {
+	t := p.tables
+	half := t.dsp.Nfft / 2
+	for n := 0; n < half; n++ {
+		p.packed[n] = complex(samples[2*n]*t.hann[2*n], samples[2*n+1]*t.hann[2*n+1])
+	}
+	t.fft_in_place(p.packed)
+
+	for k := 0; k <= half; k++ {
+		zk := p.packed[k%half]
+		zc := p.packed[(half-k)%half]
+		zc = complex(real(zc), -imag(zc))
+		even := (zk + zc) * 0.5
+		odd := (zk - zc) * complex(0, -0.5)
+		bin := even + t.realTwiddles[k]*odd
+		power[k] = real(bin)*real(bin) + imag(bin)*imag(bin)
+	}
+}
+
+// Radix-2 (decimation in time) FFT of Nfft/2 values, without allocating
+func (t *dsp_tables) fft_in_place(x []complex128) {
+	for i, j := range t.fftReversed {
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= len(x); size <<= 1 {
+		half := size / 2
+		step := len(x) / size
+		for start := 0; start < len(x); start += size {
+			for k := 0; k < half; k++ {
+				a := x[start+k]
+				w := x[start+k+half] * t.fftTwiddles[k*step]
+				x[start+k] = a + w
+				x[start+k+half] = a - w
+			}
+		}
+	}
+}
+
+// Check window shared by several models, prepared once for each DSP setting
+type Window struct {
+	PCM      []byte
+	lock     sync.Mutex
+	prepared map[DSP]*tensor.Dense
+}
+
+// Share a check window; pcmData must not be changed afterwards
+func New_Window(pcmData []byte) *Window {
+	return &Window{PCM: pcmData, prepared: make(map[DSP]*tensor.Dense)}
+}
+
+// Prepare the window for one DSP setting (only the first time it is needed)
+func (w *Window) Prepare(dsp DSP) (*tensor.Dense, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if prepared, ok := w.prepared[dsp]; ok {
+		return prepared, nil
+	}
+	prepared, err := Prepare_DSP(dsp, w.PCM)
+	if err != nil {
+		return nil, err
+	}
+	w.prepared[dsp] = prepared
+	return prepared, nil
+}
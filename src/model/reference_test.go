@@ -0,0 +1,324 @@
+package model
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+	"dtrack/log"
+
+	// Standard
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+
+	// 3rd-Party
+	"github.com/mjibson/go-dsp/fft"
+	"github.com/mjibson/go-dsp/window"
+	"gorgonia.org/tensor"
+)
+
+// Check window size, and the mel filterbank the original Prepare used
+var (
+	reference_size                    = ffmpeg.DefaultSampleRate * ffmpeg.SampleBytes * SegmentSize
+	cachedMelWeights, cachedMelBounds = mel_filterbank(Default_DSP)
+)
+
+// Original Prepare, which allocated every intermediate buffer (see reference_test)
+func reference_prepare(pcmData []byte) (*tensor.Dense, error) {
+	// 1. Pad/Truncate the data to ensure fixed length (reference_size)
+	if len(pcmData) < reference_size {
+		log.Warn("Audio Underflow; Segment was not large enough!")
+		padding := make([]byte, reference_size-len(pcmData))
+		pcmData = append(pcmData, padding...)
+	}
+	if len(pcmData) > reference_size {
+		log.Warn("Audio Overflow; Segment was too large!")
+		pcmData = pcmData[:reference_size]
+	}
+
+	// 2. Normalize to float64 for DSP
+	audioFloat32 := normalizeAudio(pcmData)
+	audioFloat64 := make([]float64, len(audioFloat32))
+	for i, v := range audioFloat32 {
+		audioFloat64[i] = float64(v)
+	}
+
+	// 3. STFT Calculation
+	n_frames_calculated := (len(audioFloat64)-Nfft)/HopLength + 1
+
+	// Check for extreme case where calculated frames exceed expected
+	if n_frames_calculated > SpectrogramFrames {
+		log.Warn("ML: Calculated frames exceed expected. Using calculated size.")
+		n_frames_calculated = SpectrogramFrames
+	}
+
+	// Calculate bins to keep based on Full Spectrum (Matches Python)
+	bins_linear := Nfft/2 + 1 // 1025 bins
+
+	// Create Power Spectrogram (bins_linear * frames)
+	powerSpectrogram := make([][]float64, bins_linear)
+	for i := range powerSpectrogram {
+		powerSpectrogram[i] = make([]float64, n_frames_calculated)
+	}
+
+	window_func := window.Hann(Nfft)
+
+	for i := 0; i < n_frames_calculated; i++ {
+		start := i * HopLength
+		end := start + Nfft
+		if end > len(audioFloat64) {
+			end = len(audioFloat64)
+		}
+
+		segment := audioFloat64[start:end]
+		windowed := make([]float64, Nfft)
+		for j := 0; j < len(segment); j++ {
+			windowed[j] = segment[j] * window_func[j]
+		}
+
+		complex_input := make([]complex128, Nfft)
+		for j := 0; j < Nfft; j++ {
+			complex_input[j] = complex(windowed[j], 0)
+		}
+		complex_result := fft.FFT(complex_input)
+
+		// Magnitude Square
+		for j := 0; j < bins_linear; j++ {
+			val := complex_result[j]
+			magSq := real(val)*real(val) + imag(val)*imag(val)
+			powerSpectrogram[j][i] = magSq
+		}
+	}
+
+	// 4. Convert to Mel Spectrogram
+	melSpectrogram := applyMelFilterbank(powerSpectrogram, bins_linear, n_frames_calculated)
+	melDb := powerToDb(melSpectrogram)
+
+	// 5. Normalize for tensor (-80db floor)
+	normalized := fixedNormalize(melDb)
+	flatData := make([]float32, Nmels*SpectrogramFrames)
+
+	idx := 0
+	for r := 0; r < Nmels; r++ {
+		for c := 0; c < SpectrogramFrames; c++ {
+			if c < n_frames_calculated {
+				flatData[idx] = float32(normalized[r][c])
+			} else {
+				//log.Warn("Padding used during data normalization")
+				flatData[idx] = 0.0
+			}
+			idx++
+		}
+	}
+
+	// Final tensor shape: [Batch, Channel, Height, Width]
+	shape := []int{1, 1, Nmels, SpectrogramFrames}
+
+	inputTensor := tensor.New(
+		tensor.Of(tensor.Float32),
+		tensor.WithShape(shape...),
+		tensor.WithBacking(flatData),
+	)
+
+	return inputTensor, nil
+}
+
+// Convert raw 16-bit PCM bytes into a normalized float32 slice
+func normalizeAudio(pcmData []byte) []float32 {
+	numSamples := len(pcmData) / 2
+	audioArray := make([]float32, numSamples)
+	for i := 0; i < numSamples; i++ {
+		val := int16(uint16(pcmData[i*2]) | uint16(pcmData[i*2+1])<<8)
+		audioArray[i] = float32(val) / Int16Max
+	}
+	return audioArray
+}
+
+// Converts a Linear Power Spectrogram to a Mel Spectrogram; Replicates librosa.filters.mel()
+func applyMelFilterbank(powerSpec [][]float64, numLinearBins, numFrames int) [][]float64 {
+	// Initialize Output
+	melSpec := make([][]float64, Nmels)
+	for i := range melSpec {
+		melSpec[i] = make([]float64, numFrames)
+	}
+
+	// Loop Mel Bins (rows)
+	for m := 0; m < Nmels; m++ {
+		// Retrieve pre-calculated optimization bounds
+		start := cachedMelBounds[m].start
+		end := cachedMelBounds[m].end
+		if end > numLinearBins {
+			end = numLinearBins
+		}
+
+		// Loop Time Frames (columns)
+		for t := 0; t < numFrames; t++ {
+			sum := 0.0
+
+			// Only iterate where the filter is not zero
+			for k := start; k < end; k++ {
+				sum += cachedMelWeights[m][k] * powerSpec[k][t]
+			}
+			melSpec[m][t] = sum
+		}
+	}
+	return melSpec
+}
+
+// Clips DB to [-80, 0] and scales to [0.0, 1.0]
+func fixedNormalize(dbSpec [][]float64) [][]float64 {
+	rows := len(dbSpec)
+	cols := len(dbSpec[0])
+	norm := make([][]float64, rows)
+
+	for r := 0; r < rows; r++ {
+		norm[r] = make([]float64, cols)
+		for c := 0; c < cols; c++ {
+			val := dbSpec[r][c]
+			if val < -80.0 {
+				val = -80.0
+			}
+			if val > 0.0 {
+				val = 0.0
+			}
+			norm[r][c] = (val + 80.0) / 80.0
+		}
+	}
+	return norm
+}
+
+// Converts a power spectrogram to decibels
+func powerToDb(spec [][]float64) [][]float64 {
+	rows := len(spec)
+	cols := len(spec[0])
+	dbSpec := make([][]float64, rows)
+
+	// Find Global Max
+	var maxVal float64 = 1e-10
+	for r := 0; r < rows; r++ {
+		dbSpec[r] = make([]float64, cols)
+		for c := 0; c < cols; c++ {
+			if spec[r][c] > maxVal {
+				maxVal = spec[r][c]
+			}
+		}
+	}
+
+	// Log Calculation
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			val := spec[r][c]
+			if val < 1e-10 {
+				val = 1e-10
+			}
+			dbSpec[r][c] = 10.0 * math.Log10(val/maxVal)
+		}
+	}
+	return dbSpec
+}
+
+// Check windows covering silence, tones, noise, clipping, and short input
+func reference_windows(t testing.TB) map[string][]byte {
+	windows := map[string][]byte{
+		"silence": make([]byte, reference_size),
+		"short":   make([]byte, reference_size/3),
+	}
+	tone := make([]byte, reference_size)
+	noise := make([]byte, reference_size)
+	clipped := make([]byte, reference_size)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < reference_size/2; i++ {
+		binary.LittleEndian.PutUint16(tone[i*2:], uint16(int16(8000*math.Sin(float64(i)*0.05))))
+		binary.LittleEndian.PutUint16(noise[i*2:], uint16(random.Intn(65536)))
+		binary.LittleEndian.PutUint16(clipped[i*2:], uint16(int16(32767*math.Copysign(1, math.Sin(float64(i)*0.3)))))
+	}
+	windows["tone"] = tone
+	windows["noise"] = noise
+	windows["clipped"] = clipped
+	// Copied, so padding the short window cannot overwrite the full tone
+	windows["short-tone"] = slices.Clone(tone[:reference_size/2+7])
+
+	// Recorded samples, when available
+	for _, path := range []string{"test_empty.dat", "test_smalldog.dat", "test_bigdog.dat", "test_combo.dat"} {
+		if data, err := os.ReadFile(path); err == nil {
+			windows[path] = data
+		}
+	}
+	return windows
+}
+
+// Reusable buffers produce exactly the same tensor as the original Prepare
+func TestPrepare_Identical(t *testing.T) {
+	preparer := New_Preparer(Default_DSP)
+	for name, window := range reference_windows(t) {
+		expected, err := reference_prepare(window)
+		if err != nil {
+			t.Fatalf("%s: reference failed: %v", name, err)
+		}
+
+		// Twice, so leftovers from the previous window would show up
+		for run := 0; run < 2; run++ {
+			actual, err := preparer.Prepare(window)
+			if err != nil {
+				t.Fatalf("%s: Prepare failed: %v", name, err)
+			}
+			if !slices.Equal(actual.Shape(), expected.Shape()) {
+				t.Fatalf("%s: shape %v, expected %v", name, actual.Shape(), expected.Shape())
+			}
+			expected_data := expected.Data().([]float32)
+			for i, value := range actual.Data().([]float32) {
+				if math.Float32bits(value) != math.Float32bits(expected_data[i]) {
+					t.Errorf("%s: value %d is %v, expected %v", name, i, value, expected_data[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+// Preparing into an existing buffer allocates nothing
+func TestSpectrogram_Allocations(t *testing.T) {
+	preparer := New_Preparer(Default_DSP)
+	window := reference_windows(t)["noise"]
+	output := make([]float32, Nmels*SpectrogramFrames)
+	allocations := testing.AllocsPerRun(10, func() {
+		preparer.Spectrogram(window, output)
+	})
+	if allocations != 0 {
+		t.Errorf("Expected no allocations, got %.0f", allocations)
+	}
+}
+
+// Original Prepare, for comparison
+func BenchmarkPrepare_Reference(b *testing.B) {
+	window := reference_windows(b)["noise"]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reference_prepare(window)
+	}
+}
+
+// Prepare using pooled buffers (only the tensor is allocated)
+func BenchmarkPrepare(b *testing.B) {
+	window := reference_windows(b)["noise"]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Prepare(window)
+	}
+}
+
+// Preparing into an existing buffer
+func BenchmarkSpectrogram(b *testing.B) {
+	preparer := New_Preparer(Default_DSP)
+	window := reference_windows(b)["noise"]
+	output := make([]float32, Nmels*SpectrogramFrames)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		preparer.Spectrogram(window, output)
+	}
+}
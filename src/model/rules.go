@@ -0,0 +1,127 @@
+package model
+
+import (
+	// DTrack
+	"dtrack/ffmpeg"
+
+	// Standard
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Rule-based detector: windows louder than a threshold
+type Loudness struct {
+	Class     string  `json:"class"`     // Class of loud windows
+	Threshold float64 `json:"threshold"` // Level (dBFS) with a probability of 0.5
+	Measure   string  `json:"measure"`   // Level compared: "rms" or "peak"
+	Range     float64 `json:"range"`     // Decibels over which probability rises from 0 to 1
+}
+
+// Create a loudness detector from its params
+func New_Loudness(params json.RawMessage) (Detector, error) {
+	loudness := &Loudness{Class: "loud", Threshold: -20, Measure: "rms", Range: 6}
+	if err := decode_params(params, loudness); err != nil {
+		return nil, err
+	}
+	switch {
+	case loudness.Class == "" || loudness.Class == empty:
+		return nil, &Error{Kind: ErrParams, Err: fmt.Errorf("invalid class %q", loudness.Class)}
+	case loudness.Measure != "rms" && loudness.Measure != "peak":
+		return nil, &Error{Kind: ErrParams, Err: fmt.Errorf("measure must be rms or peak")}
+	case loudness.Range <= 0:
+		return nil, &Error{Kind: ErrParams, Err: fmt.Errorf("range must be positive")}
+	}
+	return loudness, nil
+}
+
+// Probability rises linearly across Range, centered on Threshold
+func (l *Loudness) Detect(windows []*Window) ([]map[string]float64, error) {
+	results := make([]map[string]float64, len(windows))
+	for i, window := range windows {
+		level, peak := Level(window.PCM)
+		if l.Measure == "peak" {
+			level = peak
+		}
+		probability := math.Min(math.Max(0.5+(level-l.Threshold)/l.Range, 0), 1)
+		results[i] = map[string]float64{l.Class: probability, empty: 1 - probability}
+	}
+	return results, nil
+}
+
+// Describe a loudness detector by its class, measure and threshold
+func (l *Loudness) Describe() string {
+	return fmt.Sprintf("%s: %s above %.1f dBFS", l.Class, l.Measure, l.Threshold)
+}
+
+// Rule-based detector: windows with most of their energy in one frequency band
+// Suited to steady tones, like smoke alarms or reversing beepers.
+type Band struct {
+	Class     string  `json:"class"`     // Class of windows with energy in the band
+	Low       float64 `json:"low"`       // Lowest frequency (Hz) of the band
+	High      float64 `json:"high"`      // Highest frequency (Hz) of the band
+	Min_Level float64 `json:"min_level"` // Quieter windows (peak dBFS) never match
+}
+
+// Create a frequency band detector from its params
+func New_Band(params json.RawMessage) (Detector, error) {
+	band := &Band{Class: "tone", Min_Level: -60}
+	if err := decode_params(params, band); err != nil {
+		return nil, err
+	}
+	nyquist := float64(ffmpeg.Sample_Rate()) / 2
+	switch {
+	case band.Class == "" || band.Class == empty:
+		return nil, &Error{Kind: ErrParams, Err: fmt.Errorf("invalid class %q", band.Class)}
+	case band.Low < 0 || band.High <= band.Low || band.High > nyquist:
+		return nil, &Error{Kind: ErrParams, Err: fmt.Errorf("band must be within 0-%.0f Hz (low < high)", nyquist)}
+	}
+	return band, nil
+}
+
+// Probability is the share of a window's energy inside the band
+func (b *Band) Detect(windows []*Window) ([]map[string]float64, error) {
+	results := make([]map[string]float64, len(windows))
+	for i, window := range windows {
+		probability := 0.0
+		if _, peak := Level(window.PCM); peak >= b.Min_Level {
+			probability = b.share(window.PCM)
+		}
+		results[i] = map[string]float64{b.Class: probability, empty: 1 - probability}
+	}
+	return results, nil
+}
+
+// Share of power within the band, across every frame of a check window
+func (b *Band) share(pcmData []byte) float64 {
+	pool, preparer := borrow(Recording_DSP())
+	defer pool.Put(preparer)
+	preparer.power_spectrum(pcmData)
+
+	// Bin k holds frequency k * Sample_Rate / Nfft
+	t := preparer.tables
+	hz_per_bin := float64(t.dsp.Sample_Rate) / float64(t.dsp.Nfft)
+	low := int(math.Ceil(b.Low / hz_per_bin))
+	high := int(math.Floor(b.High / hz_per_bin))
+
+	inside, total := 0.0, 0.0
+	for f := 0; f < t.frames; f++ {
+		frame := preparer.power[f*t.bins : (f+1)*t.bins]
+		// Bin 0 (DC offset) is not sound
+		for k := 1; k < t.bins; k++ {
+			total += frame[k]
+			if k >= low && k <= high {
+				inside += frame[k]
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return inside / total
+}
+
+// Describe a band detector by its class and frequency range
+func (b *Band) Describe() string {
+	return fmt.Sprintf("%s: %.0f-%.0f Hz", b.Class, b.Low, b.High)
+}
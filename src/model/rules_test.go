@@ -0,0 +1,101 @@
+package model_test
+
+import (
+	// DTrack
+	"dtrack/model"
+	"dtrack/state"
+
+	// Standard
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Check window of a sine wave (frequency in Hz, amplitude of full scale)
+func tone_window(frequency float64, amplitude float64) *model.Window {
+	pcm := make([]byte, model.Sample_Size())
+	for i := 0; i < len(pcm)/2; i++ {
+		value := int16(amplitude * 32767 * math.Sin(2*math.Pi*frequency*float64(i)/48000))
+		pcm[i*2], pcm[i*2+1] = byte(value), byte(uint16(value)>>8)
+	}
+	return model.New_Window(pcm)
+}
+
+// Check window of quiet white noise
+func noise_window() *model.Window {
+	random := rand.New(rand.NewSource(1))
+	pcm := make([]byte, model.Sample_Size())
+	for i := 0; i < len(pcm)/2; i++ {
+		value := int16(random.NormFloat64() * 1000)
+		pcm[i*2], pcm[i*2+1] = byte(value), byte(uint16(value)>>8)
+	}
+	return model.New_Window(pcm)
+}
+
+// Loud windows match; probability rises across the configured range
+func TestLoudness(t *testing.T) {
+	detector, err := model.New_Detector(state.Detector{
+		Name:   "loud",
+		Type:   state.Detector_Loudness,
+		Params: json.RawMessage(`{"class": "alarm", "threshold": -12, "measure": "peak"}`),
+	}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Peaks of -6 dBFS (half scale), -12 dBFS, and silence
+	results, err := detector.Detect([]*model.Window{
+		tone_window(1000, 0.5), tone_window(1000, 0.25), model.New_Window(make([]byte, model.Sample_Size())),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, expected := range []float64{1, 0.5, 0} {
+		if math.Abs(results[i]["alarm"]-expected) > 0.01 || math.Abs(results[i]["empty"]-(1-expected)) > 0.01 {
+			t.Errorf("Window %d: expected alarm %.2f, got %v", i, expected, results[i])
+		}
+	}
+}
+
+// Tones inside the band match; noise and other tones do not
+func TestBand(t *testing.T) {
+	detector, err := model.New_Detector(state.Detector{
+		Name:   "smoke",
+		Type:   state.Detector_Band,
+		Params: json.RawMessage(`{"class": "smoke_alarm", "low": 2900, "high": 3300}`),
+	}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	results, err := detector.Detect([]*model.Window{
+		tone_window(3100, 0.5), tone_window(500, 0.5), noise_window(), tone_window(3100, 0.0001),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if results[0]["smoke_alarm"] < 0.9 {
+		t.Errorf("Expected a 3100 Hz tone to match, got %v", results[0])
+	}
+	for i, name := range []string{"500 Hz tone", "noise", "quiet tone"} {
+		if results[i+1]["smoke_alarm"] > 0.1 {
+			t.Errorf("Expected %s not to match, got %v", name, results[i+1])
+		}
+	}
+}
+
+// Unknown or impossible params are rejected
+func TestNew_Detector_Invalid(t *testing.T) {
+	for _, detector := range []state.Detector{
+		{Type: state.Detector_Loudness, Params: json.RawMessage(`{"treshold": -10}`)},
+		{Type: state.Detector_Loudness, Params: json.RawMessage(`{"measure": "mean"}`)},
+		{Type: state.Detector_Band, Params: json.RawMessage(`{"low": 3000, "high": 2000}`)},
+		{Type: state.Detector_Band, Params: json.RawMessage(`{"low": 3000, "high": 30000}`)},
+	} {
+		if _, err := model.New_Detector(detector, ""); !errors.Is(err, model.ErrParams) {
+			t.Errorf("Expected invalid params for %s, got %v", detector.Params, err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// ##
+// DTrack Package: Automatic Reporting
+//
+// Summarizes recorded incidents into a single, self-contained HTML file.
+// ##
+package report
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/log"
+	"dtrack/state"
+
+	// Standard
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Number of entries shown in the "Longest Incidents" table
+const LongestCount = 10
+
+//go:embed report.html
+var report_html string
+
+// Everything displayed in a report
+type Summary struct {
+	From      time.Time
+	Until     time.Time
+	Generated time.Time
+	Total     int
+	Seconds   float64
+	Days      []Day
+	Classes   []Class_Total
+	Longest   []Linked_Incident
+	Hour_Max  int
+}
+
+// Incidents seen on a single day
+type Day struct {
+	Date    string
+	Count   int
+	Seconds float64
+	Hours   [24]int
+}
+
+// Incidents seen for a single model/class
+type Class_Total struct {
+	Model   string
+	Class   string
+	Count   int
+	Seconds float64
+	Peak    float64
+}
+
+// Incident with a link to its recording
+type Linked_Incident struct {
+	events.Incident
+	Link string
+}
+
+// Primary post-bootstrap entry point
+// Write an HTML report of all incidents between since and until (inclusive)
+func Run(since string, until string, output string) {
+	from, to, err := events.Parse_Range(since, until)
+	if err != nil {
+		log.Die("Invalid date range: %s", err)
+	}
+
+	incidents, err := events.Load_Incidents()
+	if err != nil {
+		log.Die("Unable to read incidents: %s", err)
+	}
+
+	// Default output: <workspace>/reports/YYYY-MM-DD_YYYY-MM-DD.html
+	if output == "" {
+		output = filepath.Join(state.Runtime.Workspace, "reports",
+			from.Format(events.DateFormat)+"_"+last_day(to).Format(events.DateFormat)+".html")
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		log.Die("Failed to make output directory: %s", filepath.Dir(output))
+	}
+
+	summary := Summarize(incidents, from, to, filepath.Dir(output))
+	fh, err := os.Create(output)
+	if err != nil {
+		log.Die("Unable to create report: %s", err)
+	}
+	defer fh.Close()
+	if err := Render(fh, summary); err != nil {
+		log.Die("Unable to write report: %s", err)
+	}
+	log.Info("Report with %d incidents saved to %s", summary.Total, output)
+}
+
+// Collect report data from incidents starting within [from, to)
+// Links to recordings are relative to report_dir.
+func Summarize(incidents []events.Incident, from time.Time, to time.Time, report_dir string) Summary {
+	summary := Summary{From: from, Until: last_day(to), Generated: time.Now()}
+
+	// One entry for every (local) day, even if nothing happened
+	day_index := make(map[string]int)
+	for day := midnight(from); !day.After(summary.Until); day = day.AddDate(0, 0, 1) {
+		day_index[day.Format(events.DateFormat)] = len(summary.Days)
+		summary.Days = append(summary.Days, Day{Date: day.Format(events.DateFormat)})
+	}
+
+	class_index := make(map[string]int)
+	linked := []Linked_Incident{}
+	for _, incident := range incidents {
+		start := incident.Start.In(time.Local)
+		if start.Before(from) || !start.Before(to) {
+			continue
+		}
+		index, ok := day_index[start.Format(events.DateFormat)]
+		if !ok {
+			continue
+		}
+		summary.Total++
+		summary.Seconds += incident.Duration
+
+		// Per-day and hour-of-day totals
+		day := &summary.Days[index]
+		day.Count++
+		day.Seconds += incident.Duration
+		day.Hours[start.Hour()]++
+		if day.Hours[start.Hour()] > summary.Hour_Max {
+			summary.Hour_Max = day.Hours[start.Hour()]
+		}
+
+		// Per-class totals
+		key := incident.Model + "/" + incident.Class
+		if _, ok := class_index[key]; !ok {
+			class_index[key] = len(summary.Classes)
+			summary.Classes = append(summary.Classes, Class_Total{
+				Model: incident.Model,
+				Class: incident.Class,
+			})
+		}
+		class := &summary.Classes[class_index[key]]
+		class.Count++
+		class.Seconds += incident.Duration
+		if incident.Peak > class.Peak {
+			class.Peak = incident.Peak
+		}
+
+		linked = append(linked, Linked_Incident{
+			Incident: incident,
+			Link:     recording_link(incident, report_dir),
+		})
+	}
+
+	// Busiest classes first
+	sort.SliceStable(summary.Classes, func(i, j int) bool {
+		return summary.Classes[i].Seconds > summary.Classes[j].Seconds
+	})
+
+	// Longest incidents first
+	sort.SliceStable(linked, func(i, j int) bool {
+		return linked[i].Duration > linked[j].Duration
+	})
+	if len(linked) > LongestCount {
+		linked = linked[:LongestCount]
+	}
+	summary.Longest = linked
+	return summary
+}
+
+// Local midnight at the start of the day holding t
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// Midnight of the last day within [from, to)
+func last_day(to time.Time) time.Time {
+	return midnight(to.Add(-time.Nanosecond))
+}
+
+// Relative link to the recording holding an incident, starting at its offset
+func recording_link(incident events.Incident, report_dir string) string {
+	if incident.Recording == "" {
+		return ""
+	}
+	recording := filepath.Join(state.Runtime.Workspace, "recordings", incident.Recording)
+	if relative, err := filepath.Rel(report_dir, recording); err == nil {
+		recording = relative
+	}
+	return fmt.Sprintf("%s#t=%d", filepath.ToSlash(recording), incident.Offset)
+}
+
+// Write a summary as HTML
+func Render(out io.Writer, summary Summary) error {
+	page, err := template.New("report").Funcs(template.FuncMap{
+		"duration": format_duration,
+		"heat":     heat_style,
+		"hours":    func() [24]int { return [24]int{} },
+		"stamp":    func(t time.Time) string { return t.In(time.Local).Format("2006-01-02 15:04:05") },
+	}).Parse(report_html)
+	if err != nil {
+		return err
+	}
+	return page.Execute(out, summary)
+}
+
+// Human-readable duration (e.g. 1h 02m 03s)
+func format_duration(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %02dm %02ds", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm %02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+// Background shading for one heatmap cell
+func heat_style(count int, max int) template.CSS {
+	if count == 0 || max == 0 {
+		return ""
+	}
+	return template.CSS(fmt.Sprintf("background-color: rgba(200, 30, 30, %.2f)",
+		0.15+0.85*float64(count)/float64(max)))
+}
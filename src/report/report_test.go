@@ -0,0 +1,115 @@
+package report_test
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/report"
+	"dtrack/state"
+
+	// Standard
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Build an incident starting at a local date/time
+func incident_at(day int, hour int, class string, seconds float64) events.Incident {
+	start := time.Date(2025, 6, day, hour, 15, 0, 0, time.Local)
+	return events.Incident{
+		Model:     "dog",
+		Class:     class,
+		Start:     start,
+		End:       start.Add(time.Duration(seconds) * time.Second),
+		Duration:  seconds,
+		Peak:      0.9,
+		Mean:      0.7,
+		Matches:   int(seconds) - 1,
+		Recording: start.Format("2006-01-02_150405.mkv"),
+		Offset:    42,
+	}
+}
+
+// Incidents are totaled per day, hour, and class
+func TestSummarize(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: "_workspace"}
+	incidents := []events.Incident{
+		incident_at(1, 22, "bark", 30),
+		incident_at(1, 22, "bark", 120),
+		incident_at(2, 3, "howl", 10),
+		incident_at(5, 3, "howl", 10), // Outside range
+	}
+	from, to, _ := events.Parse_Range("2025-06-01", "2025-06-03")
+	summary := report.Summarize(incidents, from, to, "_workspace/reports")
+
+	if summary.Total != 3 || summary.Seconds != 160 {
+		t.Errorf("Expected 3 incidents (160s), got %d (%.0fs)", summary.Total, summary.Seconds)
+	}
+	if len(summary.Days) != 3 {
+		t.Fatalf("Expected 3 days, got %d", len(summary.Days))
+	}
+	if summary.Days[0].Count != 2 || summary.Days[0].Hours[22] != 2 || summary.Hour_Max != 2 {
+		t.Errorf("Unexpected totals for first day: %+v", summary.Days[0])
+	}
+	if summary.Days[2].Count != 0 {
+		t.Errorf("Expected quiet final day, got %d incidents", summary.Days[2].Count)
+	}
+	if len(summary.Classes) != 2 || summary.Classes[0].Class != "bark" || summary.Classes[0].Count != 2 {
+		t.Errorf("Unexpected class totals: %+v", summary.Classes)
+	}
+	if summary.Longest[0].Duration != 120 {
+		t.Errorf("Longest incident should be first, got %.0fs", summary.Longest[0].Duration)
+	}
+	if summary.Longest[0].Link != "../recordings/2025-06-01_221500.mkv#t=42" {
+		t.Errorf("Unexpected recording link: %s", summary.Longest[0].Link)
+	}
+}
+
+// Ranges with a time of day still total by whole (local) days
+func TestSummarize_Times(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: "_workspace"}
+	incidents := []events.Incident{
+		incident_at(1, 13, "bark", 30), // Before range
+		incident_at(1, 22, "bark", 30),
+		incident_at(2, 3, "howl", 10),
+		incident_at(3, 9, "howl", 10),
+		incident_at(3, 11, "howl", 10), // After range
+	}
+	from, to, _ := events.Parse_Range("2025-06-01 14:00", "2025-06-03 10:00")
+	summary := report.Summarize(incidents, from, to, "_workspace/reports")
+
+	if summary.Total != 3 || len(summary.Days) != 3 {
+		t.Fatalf("Expected 3 incidents over 3 days, got %d over %d", summary.Total, len(summary.Days))
+	}
+	for i, date := range []string{"2025-06-01", "2025-06-02", "2025-06-03"} {
+		if summary.Days[i].Date != date || summary.Days[i].Count != 1 {
+			t.Errorf("Expected 1 incident on %s, got %+v", date, summary.Days[i])
+		}
+	}
+	if summary.Until.Format(events.DateFormat) != "2025-06-03" {
+		t.Errorf("Expected report until 2025-06-03, got %s", summary.Until)
+	}
+}
+
+// Rendered reports include every section
+func TestRender(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: "_workspace"}
+	from, to, _ := events.Parse_Range("2025-06-01", "2025-06-01")
+	summary := report.Summarize(
+		[]events.Incident{incident_at(1, 22, "bark", 3723)}, from, to, "_workspace/reports")
+
+	var out bytes.Buffer
+	if err := report.Render(&out, summary); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	html := out.String()
+	for _, expected := range []string{
+		"Incidents per Day", "Incidents by Hour of Day", "Totals per Class", "Longest Incidents",
+		"1h 02m 03s", "background-color: rgba(200, 30, 30, 1.00)",
+		`href="../recordings/2025-06-01_221500.mkv#t=42"`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Report is missing %q", expected)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// ##
+// DTrack Package: Recording Retention
+//
+// Removes the oldest recordings to enforce age, size, and free-space limits,
+// while keeping anything that is (or may become) evidence.
+// ##
+package retention
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/export"
+	"dtrack/ffmpeg"
+	"dtrack/ledger"
+	"dtrack/log"
+	"dtrack/state"
+
+	// Standard
+	"archive/zip"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Bytes in one configured megabyte
+const Megabyte = 1024 * 1024
+
+// Time between janitor runs in the daemon
+const JanitorInterval = time.Minute
+
+// Primary post-bootstrap entry point
+// Apply retention once, optionally only listing what would be removed
+func Run(dry_run bool) {
+	removed, err := Clean(dry_run)
+	if err != nil {
+		log.Die("Retention failed: %s", err)
+	}
+	if len(removed) == 0 {
+		log.Info("Nothing to remove")
+	}
+}
+
+// Background janitor for the monitor; never returns
+// In dry_run mode, recordings are only listed when the list changes.
+func Janitor(dry_run bool) {
+	listed := []string{}
+	for {
+		if dry_run {
+			listed = list_changes(listed)
+		} else if _, err := Clean(false); err != nil {
+			log.Warn("Retention failed: %s", err)
+		}
+		time.Sleep(JanitorInterval)
+	}
+}
+
+// List recordings that would be removed, if they differ from those already listed
+func list_changes(listed []string) []string {
+	pending, err := Pending()
+	if err != nil {
+		log.Warn("Retention failed: %s", err)
+		return listed
+	}
+	names := make([]string, len(pending))
+	for i, recording := range pending {
+		names[i] = recording.Name
+	}
+	if slices.Equal(names, listed) {
+		return listed
+	}
+	for _, recording := range pending {
+		log.Info("Would remove %s (%d MB)", recording.Name, recording.Size/Megabyte)
+	}
+	return names
+}
+
+// Returns true if any retention limit is configured
+func Enabled() bool {
+	return state.Runtime.Retain_Max_Days > 0 ||
+		state.Runtime.Retain_Max_MB > 0 ||
+		state.Runtime.Retain_Min_Free_MB > 0
+}
+
+// Remove (or list, if dry_run) recordings that exceed retention limits
+func Clean(dry_run bool) ([]ffmpeg.Recording, error) {
+	removed, err := Pending()
+	if err != nil {
+		return nil, err
+	}
+	for _, recording := range removed {
+		if dry_run {
+			log.Info("Would remove %s (%d MB)", recording.Name, recording.Size/Megabyte)
+			continue
+		}
+		log.Info("Removing %s (%d MB)", recording.Name, recording.Size/Megabyte)
+		if err := os.Remove(recording.Path); err != nil {
+			return nil, err
+		}
+		if err := ledger.Record_Deleted(recording.Path); err != nil {
+			log.Warn("Failed to add %s to ledger: %s", recording.Name, err)
+		}
+	}
+	return removed, nil
+}
+
+// Recordings that currently exceed retention limits
+func Pending() ([]ffmpeg.Recording, error) {
+	if !Enabled() {
+		return nil, nil
+	}
+	recordings, err := ffmpeg.List_Recordings()
+	if err != nil {
+		return nil, err
+	}
+	protected, err := Protected()
+	if err != nil {
+		return nil, err
+	}
+	free, err := free_bytes(ffmpeg.Recordings_Directory())
+	if err != nil {
+		return nil, err
+	}
+
+	return Plan(recordings, protected, free, time.Now()), nil
+}
+
+// Choose recordings to remove, oldest first
+// The newest recording (likely still being written) is never removed.
+func Plan(recordings []ffmpeg.Recording, protected map[string]bool, free int64, now time.Time) []ffmpeg.Recording {
+	removed := []ffmpeg.Recording{}
+	if len(recordings) < 2 {
+		return removed
+	}
+
+	total := int64(0)
+	for _, recording := range recordings {
+		total += recording.Size
+	}
+	max_bytes := int64(state.Runtime.Retain_Max_MB) * Megabyte
+	min_free := int64(state.Runtime.Retain_Min_Free_MB) * Megabyte
+	oldest := now.AddDate(0, 0, -state.Runtime.Retain_Max_Days)
+
+	for _, recording := range recordings[:len(recordings)-1] {
+		if protected[recording.Name] {
+			continue
+		}
+		too_old := state.Runtime.Retain_Max_Days > 0 && recording.Start.Before(oldest)
+		too_big := max_bytes > 0 && total > max_bytes
+		too_full := min_free > 0 && free < min_free
+		if !too_old && !too_big && !too_full {
+			continue
+		}
+		removed = append(removed, recording)
+		total -= recording.Size
+		free += recording.Size
+	}
+	return removed
+}
+
+// Recordings referenced by detections, incidents, tags, or exports
+func Protected() (map[string]bool, error) {
+	protected := make(map[string]bool)
+
+	// Detections and incidents
+	detections, err := events.Load_Detections()
+	if err != nil {
+		return nil, err
+	}
+	for _, detection := range detections {
+		protected[detection.Recording] = true
+	}
+	incidents, err := events.Load_Incidents()
+	if err != nil {
+		return nil, err
+	}
+	for _, incident := range incidents {
+		protected[incident.Recording] = true
+	}
+
+	// Tagged clips: <workspace>/tags/<tag>/<recording>:<frame>.dat
+	tags := filepath.Join(state.Runtime.Workspace, "tags")
+	err = filepath.WalkDir(tags, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if recording, _, ok := strings.Cut(entry.Name(), ":"); ok {
+			protected[recording] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Export bundles (directories or zip files) inside the workspace
+	exports := filepath.Join(state.Runtime.Workspace, "exports")
+	err = filepath.WalkDir(exports, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		var manifest export.Manifest
+		switch {
+		case entry.Name() == export.ManifestName:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				log.Warn("Unable to read %s: %s", path, err)
+			}
+		case strings.HasSuffix(entry.Name(), ".zip"):
+			if err := read_zip_manifest(path, &manifest); err != nil {
+				log.Warn("Unable to read %s: %s", path, err)
+			}
+		}
+		for _, bundle := range manifest.Entries {
+			for _, clip := range bundle.Clips {
+				protected[clip.Source] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delete(protected, "")
+	return protected, nil
+}
+
+// Read the manifest from an exported zip file
+func read_zip_manifest(path string, manifest *export.Manifest) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	fh, err := archive.Open(export.ManifestName)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	return json.NewDecoder(fh).Decode(manifest)
+}
+
+// Bytes available to unprivileged users on the filesystem holding path
+func free_bytes(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,135 @@
+package retention_test
+
+import (
+	// DTrack
+	"dtrack/events"
+	"dtrack/export"
+	"dtrack/ffmpeg"
+	"dtrack/retention"
+	"dtrack/state"
+
+	// Standard
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Build one 100 MB recording per day, ending today
+func daily_recordings(days int, now time.Time) []ffmpeg.Recording {
+	recordings := []ffmpeg.Recording{}
+	for day := days - 1; day >= 0; day-- {
+		start := now.AddDate(0, 0, -day)
+		recordings = append(recordings, ffmpeg.Recording{
+			Name:  start.Format(ffmpeg.SaveName),
+			Size:  100 * retention.Megabyte,
+			Start: start,
+			End:   start.Add(10 * time.Minute),
+		})
+	}
+	return recordings
+}
+
+// Returns the names of planned removals
+func names(recordings []ffmpeg.Recording) []string {
+	found := []string{}
+	for _, recording := range recordings {
+		found = append(found, recording.Name)
+	}
+	return found
+}
+
+// Each limit removes the oldest unprotected recordings first
+func TestPlan(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.Local)
+	recordings := daily_recordings(5, now) // 5 days, 500 MB
+	plenty := int64(100000 * retention.Megabyte)
+
+	tests := []struct {
+		name      string
+		config    state.Application_Configuration
+		protected map[string]bool
+		free      int64
+		expected  int
+	}{
+		{"No limits", state.Application_Configuration{}, nil, plenty, 0},
+		{"Max age", state.Application_Configuration{Retain_Max_Days: 2}, nil, plenty, 2},
+		{"Max size", state.Application_Configuration{Retain_Max_MB: 250}, nil, plenty, 3},
+		{"Min free", state.Application_Configuration{Retain_Min_Free_MB: 150}, nil, 0, 2},
+		{"Protected", state.Application_Configuration{Retain_Max_MB: 250},
+			map[string]bool{recordings[0].Name: true}, plenty, 3},
+		{"Never newest", state.Application_Configuration{Retain_Max_MB: 1}, nil, plenty, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state.Runtime = tt.config
+			removed := retention.Plan(recordings, tt.protected, tt.free, now)
+			if len(removed) != tt.expected {
+				t.Fatalf("Expected %d removals, got %v", tt.expected, names(removed))
+			}
+			for _, recording := range removed {
+				if tt.protected[recording.Name] {
+					t.Errorf("Protected recording removed: %s", recording.Name)
+				}
+				if recording.Name == recordings[len(recordings)-1].Name {
+					t.Errorf("Newest recording removed")
+				}
+			}
+		})
+	}
+
+	// Oldest first
+	state.Runtime = state.Application_Configuration{Retain_Max_MB: 450}
+	removed := retention.Plan(recordings, nil, plenty, now)
+	if len(removed) != 1 || removed[0].Name != recordings[0].Name {
+		t.Errorf("Expected oldest recording removed, got %v", names(removed))
+	}
+}
+
+// Recordings referenced by detections, tags, and exports are protected
+func TestProtected(t *testing.T) {
+	state.Runtime = state.Application_Configuration{Workspace: t.TempDir()}
+
+	events.Record_Detection(events.Detection{Recording: "detected.mkv"})
+	events.Record_Incident(events.Incident{Recording: "incident.mkv"})
+
+	tag_dir := filepath.Join(state.Runtime.Workspace, "tags", "bark")
+	os.MkdirAll(tag_dir, 0755)
+	os.WriteFile(filepath.Join(tag_dir, "tagged.mkv:12.dat"), []byte{}, 0644)
+
+	bundle := filepath.Join(state.Runtime.Workspace, "exports", "bundle")
+	os.MkdirAll(bundle, 0755)
+	manifest := export.Manifest{Entries: []export.Entry{{Clips: []export.Clip{{Source: "exported.mkv"}}}}}
+	if err := export.Write_Manifest(bundle, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	protected, err := retention.Protected()
+	if err != nil {
+		t.Fatalf("Protected failed: %v", err)
+	}
+	for _, name := range []string{"detected.mkv", "incident.mkv", "tagged.mkv", "exported.mkv"} {
+		if !protected[name] {
+			t.Errorf("Expected %s to be protected", name)
+		}
+	}
+	if len(protected) != 4 {
+		t.Errorf("Unexpected protected recordings: %v", protected)
+	}
+}
+
+// Retention is opt-in; a default configuration never removes recordings
+func TestEnabled_Default(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := state.Read_Configuration(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	state.Runtime = config
+	if retention.Enabled() {
+		t.Error("Expected retention to be disabled by default")
+	}
+}
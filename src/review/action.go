@@ -80,7 +80,7 @@ func open_video(uri fyne.URIReadCloser, err error) {
 	go ffmpeg.ReadStdin(args, stdWriter, true)
 	for {
 		// Allocate a buffer for the audio segment
-		segment_data := make([]byte, ffmpeg.BytesPerSecond)
+		segment_data := make([]byte, ffmpeg.Bytes_Per_Second())
 
 		// Block until segment_data is full
 		_, err := io.ReadFull(stdReader, segment_data)
@@ -19,7 +19,7 @@ func menu_bar() fyne.CanvasObject {
 	// Menu length: 1+1+1+1+X = 4+X
 	menu_buttons := make(
 		[]fyne.CanvasObject, 0,
-		4+len(state.Runtime.Record_Inspect_Models))
+		4+len(state.Runtime.Trained_Models()))
 
 	// Select Video [+1]
 	menu_buttons = append(menu_buttons,
@@ -38,7 +38,7 @@ func menu_bar() fyne.CanvasObject {
 			tag_clip("empty")
 		}))
 	// Models [+X]
-	for _, model := range state.Runtime.Record_Inspect_Models {
+	for _, model := range state.Runtime.Trained_Models() {
 		menu_buttons = append(menu_buttons,
 			widget.NewButton("[ Save as ]\n"+model, func() {
 				tag_clip(model)
@@ -14,14 +14,20 @@ import (
 
 	// Standard
 	"encoding/json"
+	"fmt"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 // Master object holding loaded configuration data
 var Runtime Application_Configuration
 
+// Configuration file used to load Runtime (for reloads)
+var Config_Path string
+
 // Map json configuration to Runtime
 // Defaults set in load_config()
 type Application_Configuration struct {
@@ -29,34 +35,103 @@ type Application_Configuration struct {
 	Workspace_Keep_Temp    bool     `json:"keep_temp"`
 	Record_Audio_Device    string   `json:"audio_device"`
 	Record_Audio_Options   []string `json:"audio_options"`
+	Record_Audio_Rate      int      `json:"audio_rate"`
+	Record_Audio_Channel   int      `json:"audio_channel"`
 	Record_Video_Device    string   `json:"video_device"`
 	Record_Video_Options   []string `json:"video_options"`
 	Record_Video_Timestamp string   `json:"video_timestamp"`
 	Record_Video_Advanced  []string `json:"video_advanced"`
-	Record_Inspect_Models  []string `json:"inspect_models"`
+	Record_Inspect_Models  []Detector `json:"inspect_models"`
 	Has_Models             bool
 	Record_Inspect_Backlog int      `json:"inspect_backlog"`
 	Record_Inspect_Trust   float64  `json:"inspect_trust"`
+	Record_Trust_Overrides map[string]float64 `json:"trust_overrides"`
+	Record_Ensembles       []Ensemble `json:"ensembles"`
+	Record_Inspect_Silence float64  `json:"inspect_silence"`
+	Record_Detect_Required int      `json:"detect_required"`
+	Record_Detect_Window   int      `json:"detect_window"`
+	Record_Detect_Release  float64  `json:"detect_release"`
+	Record_Detect_Average  int      `json:"detect_average"`
+	Record_Incident_Gap    int      `json:"incident_gap"`
 	Record_Duration        string   `json:"record_duration"`
+	Record_Max_Failures    int      `json:"max_failures"`
+	Retain_Max_Days        int      `json:"retain_max_days"`
+	Retain_Max_MB          int      `json:"retain_max_mb"`
+	Retain_Min_Free_MB     int      `json:"retain_min_free_mb"`
+	Export_Pre_Roll        int      `json:"export_pre_roll"`
+	Export_Post_Roll       int      `json:"export_post_roll"`
+	Ledger_Sign            bool     `json:"ledger_sign"`
 	Train_Batch_Size       int      `json:"train_batch_size"`
 	Train_Epochs           int      `json:"train_epochs"`
 	Train_Patience         int      `json:"train_patience"`
 	Train_Rate             float64  `json:"train_rate"`
 }
 
+// Model (or rule) deciding each window, as listed in Record_Inspect_Models
+// A plain name is an ONNX model, trained from tagged clips.
+type Detector struct {
+	Name   string          `json:"name"`   // Reported as the model of each detection
+	Type   string          `json:"type"`   // One of the Detector_* values
+	Params json.RawMessage `json:"params"` // Settings of the detector type
+}
+
+// Kinds of Detector
+const (
+	Detector_ONNX     = "onnx"     // Trained model: <workspace>/models/<name>.onnx
+	Detector_Loudness = "loudness" // Level above a threshold
+	Detector_Band     = "band"     // Share of energy within a frequency band
+)
+
+// Detection rule combining several models (see Record_Ensembles)
+type Ensemble struct {
+	Name   string   `json:"name"`   // Reported as the model of each detection
+	Class  string   `json:"class"`  // Class the models must agree on
+	Rule   string   `json:"rule"`   // One of the Rule_* values
+	Models []string `json:"models"` // Models combined by the rule
+}
+
+// Supported audio sample rates (Hz)
+const (
+	MinAudioRate = 8000
+	MaxAudioRate = 192000
+)
+
+// Ways an Ensemble combines its models
+const (
+	Rule_All      = "all"      // Every model detects Class
+	Rule_Majority = "majority" // More than half of the models detect Class
+	Rule_Average  = "average"  // Mean probability of Class is above trust
+)
+
 // Map environment variables to Runtime
 var Environment_Configation_Map = map[string]string{
 	"DTRACK_WORKSPACE":       "Workspace",
 	"DTRACK_KEEP_TEMP":       "Workspace_Keep_Temp",
 	"RECORD_AUDIO_DEVICE":    "Record_Audio_Device",
 	"RECORD_AUDIO_OPTIONS":   "Record_Audio_Options",
+	"RECORD_AUDIO_RATE":      "Record_Audio_Rate",
+	"RECORD_AUDIO_CHANNEL":   "Record_Audio_Channel",
 	"RECORD_VIDEO_DEVICE":    "Record_Video_Device",
 	"RECORD_VIDEO_OPTIONS":   "Record_Video_Options",
 	"RECORD_VIDEO_ADVANCED":  "Record_Video_Advanced",
 	"RECORD_INSPECT_MODELS":  "Record_Inspect_Models",
 	"RECORD_INSPECT_BACKLOG": "Record_Inspect_Backlog",
+	"RECORD_TRUST_OVERRIDES": "Record_Trust_Overrides",
 	"RECORD_INSPECT_TRUST":   "Record_Inspect_Trust",
+	"RECORD_INSPECT_SILENCE": "Record_Inspect_Silence",
+	"RECORD_DETECT_REQUIRED": "Record_Detect_Required",
+	"RECORD_DETECT_WINDOW":   "Record_Detect_Window",
+	"RECORD_DETECT_RELEASE":  "Record_Detect_Release",
+	"RECORD_DETECT_AVERAGE":  "Record_Detect_Average",
+	"RECORD_INCIDENT_GAP":    "Record_Incident_Gap",
 	"RECORD_DURATION":        "Record_Duration",
+	"RECORD_MAX_FAILURES":    "Record_Max_Failures",
+	"RETAIN_MAX_DAYS":        "Retain_Max_Days",
+	"RETAIN_MAX_MB":          "Retain_Max_MB",
+	"RETAIN_MIN_FREE_MB":     "Retain_Min_Free_MB",
+	"EXPORT_PRE_ROLL":        "Export_Pre_Roll",
+	"EXPORT_POST_ROLL":       "Export_Post_Roll",
+	"LEDGER_SIGN":            "Ledger_Sign",
 	"TRAIN_BATCH_SIZE":       "Train_Batch_Size",
 	"TRAIN_EPOCHS":           "Train_Epochs",
 	"TRAIN_PATIENCE":         "Train_Patience",
@@ -65,12 +140,26 @@ var Environment_Configation_Map = map[string]string{
 
 // Loads Runtime configuration data into current state
 func Load_Configuration(config_path string) {
+	cfg, err := Read_Configuration(config_path)
+	if err != nil {
+		log.Die("%s; ABORT!", err)
+	}
+
+	// Update session variables
+	Config_Path = config_path
+	Runtime = cfg
+}
+
+// Read configuration (defaults, file, and environment) without applying it
+func Read_Configuration(config_path string) (Application_Configuration, error) {
 	// Default configuration values
 	cfg := Application_Configuration{
 		Workspace:              "_workspace",
 		Workspace_Keep_Temp:    false,
 		Record_Audio_Device:    "plughw",
 		Record_Audio_Options:   []string{"-f", "alsa"},
+		Record_Audio_Rate:      48000,
+		Record_Audio_Channel:   0,
 		Record_Video_Device:    "/dev/video0",
 		Record_Video_Options:   []string{
 			"-f", "v4l2", "-input_format", "h264",
@@ -81,9 +170,24 @@ func Load_Configuration(config_path string) {
 			"libx264", "-crf", "23", "-preset", "fast", "-tune", "zerolatency",
 			"-maxrate", "3M", "-bufsize", "24M"},
 		Record_Duration:        "00:10:00",
-		Record_Inspect_Models:  []string{},
+		Record_Max_Failures:    10,
+		Retain_Max_Days:        0,
+		Retain_Max_MB:          0,
+		Retain_Min_Free_MB:     0,
+		Export_Pre_Roll:        5,
+		Export_Post_Roll:       5,
+		Ledger_Sign:            false,
+		Record_Inspect_Models:  []Detector{},
 		Record_Inspect_Backlog: 5,
 		Record_Inspect_Trust:   0.50,
+		Record_Trust_Overrides: map[string]float64{},
+		Record_Ensembles:       []Ensemble{},
+		Record_Inspect_Silence: -100.0,
+		Record_Detect_Required: 1,
+		Record_Detect_Window:   1,
+		Record_Detect_Release:  0.0,
+		Record_Detect_Average:  1,
+		Record_Incident_Gap:    10,
 		Train_Batch_Size:       16,
 		Train_Epochs:           200,
 		Train_Patience:         10,
@@ -94,19 +198,18 @@ func Load_Configuration(config_path string) {
 	log.Debug("Loading configuration values from: %s", config_path)
 	if _, err := os.Stat(config_path); err != nil {
 		log.Info("Configuration file not found; using defaults.")
-		Runtime = cfg
-		return
+		return cfg, nil
 	}
 
 	// Load configuration file
 	file_data, err := os.ReadFile(config_path)
 	if err != nil {
-		log.Die("Error opening configuration file; ABORT!")
+		return cfg, fmt.Errorf("Error opening configuration file")
 	}
 
 	// Merge configuration values into cfg
 	if err := json.Unmarshal(file_data, &cfg); err != nil {
-		log.Die("Failed to parse configuration as JSON; ABORT!")
+		return cfg, fmt.Errorf("Failed to parse configuration as JSON")
 	}
 
 	// Search for known environment variables
@@ -115,7 +218,7 @@ func Load_Configuration(config_path string) {
 			log.Debug("Environment variable found: %s", env_key)
 			field := reflect.ValueOf(&cfg).Elem().FieldByName(conf_field)
 			if !field.IsValid() || !field.CanSet() {
-				log.Die("Invalid field: %s", conf_field)
+				return cfg, fmt.Errorf("Invalid field: %s", conf_field)
 			}
 
 			// Merge environment variables into cfg
@@ -126,29 +229,186 @@ func Load_Configuration(config_path string) {
 				if intVal, err := strconv.Atoi(env_value); err == nil {
 					field.SetInt(int64(intVal))
 				} else {
-					log.Die("%s is not Integer", env_key)
+					return cfg, fmt.Errorf("%s is not Integer", env_key)
 				}
 			case reflect.Float64:
-				if intVal, err := strconv.Atoi(env_value); err == nil {
-					field.SetFloat(float64(intVal))
+				if floatVal, err := strconv.ParseFloat(env_value, 64); err == nil {
+					field.SetFloat(floatVal)
 				} else {
-					log.Die("%s is not Float64", env_key)
+					return cfg, fmt.Errorf("%s is not Float64", env_key)
 				}
 			case reflect.Bool:
 				if boolVal, err := strconv.ParseBool(env_value); err == nil {
 					field.SetBool(boolVal)
 				} else {
-					log.Die("%s is not Boolean", env_key)
+					return cfg, fmt.Errorf("%s is not Boolean", env_key)
+				}
+			case reflect.Map:
+				// Only map[string]float64, as: key=value,key=value
+				if mapVal, err := parse_float_map(env_value); err == nil {
+					field.Set(reflect.ValueOf(mapVal))
+				} else {
+					return cfg, fmt.Errorf("%s is not key=decimal[,key=decimal]", env_key)
 				}
 			default:
-				log.Die("Unexpected field type for %s", conf_field)
+				return cfg, fmt.Errorf("Unexpected field type for %s", conf_field)
 			}
 		}
 	}
 
+	// Audio is always inspected as a single channel
+	if cfg.Record_Audio_Rate < MinAudioRate || cfg.Record_Audio_Rate > MaxAudioRate {
+		return cfg, fmt.Errorf("audio_rate must be between %d and %d Hz", MinAudioRate, MaxAudioRate)
+	}
+	if cfg.Record_Audio_Channel < 0 {
+		return cfg, fmt.Errorf("audio_channel must be 0 (all channels) or a channel number")
+	}
+
+	// Detections must be able to open
+	if cfg.Record_Detect_Required > max(cfg.Record_Detect_Window, 1) {
+		return cfg, fmt.Errorf("detect_required must not be more than detect_window")
+	}
+
+	// Detectors and ensembles must be complete before any scanner uses them
+	names := make(map[string]bool)
+	for _, detector := range cfg.Record_Inspect_Models {
+		if err := detector.validate(); err != nil {
+			return cfg, fmt.Errorf("Invalid detector %q: %s", detector.Name, err)
+		}
+		if names[detector.Name] {
+			return cfg, fmt.Errorf("Detector %q is listed more than once", detector.Name)
+		}
+		names[detector.Name] = true
+	}
+	for _, ensemble := range cfg.Record_Ensembles {
+		if err := ensemble.validate(); err != nil {
+			return cfg, fmt.Errorf("Invalid ensemble %q: %s", ensemble.Name, err)
+		}
+	}
+
 	// Helper variables
-	cfg.Has_Models = len(cfg.Record_Inspect_Models) > 0
+	cfg.Has_Models = len(cfg.Scanned_Models()) > 0
+	return cfg, nil
+}
 
-	// Update session variables
-	Runtime = cfg
+// Read a detector from a plain (ONNX model) name, or an object
+func (d *Detector) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*d = Detector{Name: name, Type: Detector_ONNX}
+		return nil
+	}
+
+	// Alias avoids calling UnmarshalJSON again
+	type detector Detector
+	parsed := detector{Type: Detector_ONNX}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	*d = Detector(parsed)
+	return nil
+}
+
+// Check that a detector can be created
+func (d Detector) validate() error {
+	switch {
+	case d.Name == "":
+		return fmt.Errorf("missing name")
+	case !slices.Contains([]string{Detector_ONNX, Detector_Loudness, Detector_Band}, d.Type):
+		return fmt.Errorf("unknown type %q", d.Type)
+	}
+	return nil
+}
+
+// Check that an ensemble can be evaluated
+func (e Ensemble) validate() error {
+	switch {
+	case e.Name == "":
+		return fmt.Errorf("missing name")
+	case e.Class == "":
+		return fmt.Errorf("missing class")
+	case len(e.Models) == 0:
+		return fmt.Errorf("missing models")
+	case !slices.Contains([]string{Rule_All, Rule_Majority, Rule_Average}, e.Rule):
+		return fmt.Errorf("unknown rule %q", e.Rule)
+	}
+	return nil
+}
+
+// Parse "key=value,key=value" into a map of decimals
+func parse_float_map(value string) (map[string]float64, error) {
+	parsed := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		key, number, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid pair: %s", pair)
+		}
+		floatVal, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return nil, err
+		}
+		parsed[key] = floatVal
+	}
+	return parsed, nil
+}
+
+// Confidence required for a match of one class from one model
+// Most specific wins: "model:class", then "model", then inspect_trust.
+func (c *Application_Configuration) Trust(model string, class string) float64 {
+	if trust, ok := c.Record_Trust_Overrides[model+":"+class]; ok {
+		return trust
+	}
+	if trust, ok := c.Record_Trust_Overrides[model]; ok {
+		return trust
+	}
+	return c.Record_Inspect_Trust
+}
+
+// Names of every inspection model (detector)
+func (c *Application_Configuration) Inspect_Models() []string {
+	models := make([]string, 0, len(c.Record_Inspect_Models))
+	for _, detector := range c.Record_Inspect_Models {
+		models = append(models, detector.Name)
+	}
+	return models
+}
+
+// Every model that must be scanned: inspection models, then ensemble models
+func (c *Application_Configuration) Scanned_Models() []string {
+	models := c.Inspect_Models()
+	for _, ensemble := range c.Record_Ensembles {
+		for _, name := range ensemble.Models {
+			if !slices.Contains(models, name) {
+				models = append(models, name)
+			}
+		}
+	}
+	return models
+}
+
+// Returns true if a model reports its own detections
+// Models only used by ensembles are scanned without reporting.
+func (c *Application_Configuration) Reported(model string) bool {
+	return slices.Contains(c.Inspect_Models(), model)
+}
+
+// Detector of a scanned model; models only named by ensembles are ONNX models
+func (c *Application_Configuration) Detector(model string) Detector {
+	for _, detector := range c.Record_Inspect_Models {
+		if detector.Name == model {
+			return detector
+		}
+	}
+	return Detector{Name: model, Type: Detector_ONNX}
+}
+
+// Names of the ONNX models, which are trained from tagged clips
+func (c *Application_Configuration) Trained_Models() []string {
+	models := []string{}
+	for _, name := range c.Scanned_Models() {
+		if c.Detector(name).Type == Detector_ONNX {
+			models = append(models, name)
+		}
+	}
+	return models
 }
@@ -6,6 +6,8 @@ import (
 
 	// Standard
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -59,3 +61,167 @@ func TestLoad_Configuration_WithTempFile(t *testing.T) {
 		t.Errorf("Expected Train_Rate 0.005, got %f", cfg.Train_Rate)
 	}
 }
+
+// Invalid configuration is reported, not fatal (e.g. reloading a daemon)
+func TestRead_Configuration_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{not json}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.Read_Configuration(path); err == nil {
+		t.Error("Expected error for invalid configuration")
+	}
+}
+
+// Audio formats that cannot be inspected are rejected
+func TestRead_Configuration_Audio(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	for config, valid := range map[string]bool{
+		`{}`: true,
+		`{"audio_rate": 16000, "audio_channel": 2}`: true,
+		`{"audio_rate": 100}`:                       false,
+		`{"audio_channel": -1}`:                     false,
+	} {
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := state.Read_Configuration(path)
+		if valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", config, err)
+		} else if !valid && err == nil {
+			t.Errorf("%s: expected error, got rate %d", config, cfg.Record_Audio_Rate)
+		}
+	}
+}
+
+// Detections that could never open are rejected
+func TestRead_Configuration_Detect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	for config, valid := range map[string]bool{
+		`{"detect_required": 2, "detect_window": 3}`: true,
+		`{"detect_required": 3, "detect_window": 3}`: true,
+		`{"detect_required": 3, "detect_window": 2}`: false,
+		`{"detect_required": 2}`:                     false,
+	} {
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := state.Read_Configuration(path)
+		if valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", config, err)
+		} else if !valid && err == nil {
+			t.Errorf("%s: expected error", config)
+		}
+	}
+}
+
+// Inspection models are ONNX model names, or detectors with a type and params
+func TestRead_Configuration_Detectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(config string) {
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"inspect_models": ["dog", {"name": "alarm", "type": "band", "params": {"low": 3000, "high": 3200}}],
+		"ensembles": [{"name": "both", "class": "dog", "rule": "all", "models": ["dog", "cat"]}]}`)
+	cfg, err := state.Read_Configuration(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Detector("dog").Type != state.Detector_ONNX || cfg.Detector("alarm").Type != state.Detector_Band {
+		t.Errorf("Unexpected detectors: %+v", cfg.Record_Inspect_Models)
+	}
+	if string(cfg.Detector("alarm").Params) != `{"low": 3000, "high": 3200}` {
+		t.Errorf("Unexpected params: %s", cfg.Detector("alarm").Params)
+	}
+	if trained := cfg.Trained_Models(); !slices.Equal(trained, []string{"dog", "cat"}) {
+		t.Errorf("Expected only ONNX models to be trained, got %v", trained)
+	}
+
+	for _, invalid := range []string{
+		`{"inspect_models": [{"name": "alarm", "type": "siren"}]}`,
+		`{"inspect_models": [{"type": "loudness"}]}`,
+		`{"inspect_models": ["dog", {"name": "dog", "type": "loudness"}]}`,
+	} {
+		write(invalid)
+		if _, err := state.Read_Configuration(path); err == nil {
+			t.Errorf("Expected error for %s", invalid)
+		}
+	}
+}
+
+// Most specific trust threshold wins
+func TestTrust(t *testing.T) {
+	cfg := state.Application_Configuration{
+		Record_Inspect_Trust:   0.5,
+		Record_Trust_Overrides: map[string]float64{"dog": 0.6, "dog:siren": 0.9},
+	}
+	tests := []struct {
+		model, class string
+		expected     float64
+	}{
+		{"dog", "siren", 0.9},
+		{"dog", "bark", 0.6},
+		{"clap", "clap", 0.5},
+	}
+	for _, tt := range tests {
+		if actual := cfg.Trust(tt.model, tt.class); actual != tt.expected {
+			t.Errorf("Trust(%s, %s): expected %.2f, got %.2f", tt.model, tt.class, tt.expected, actual)
+		}
+	}
+}
+
+// Decimal and mapping environment variables are parsed
+func TestRead_Configuration_Environment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RECORD_INSPECT_TRUST", "0.75")
+	t.Setenv("RECORD_TRUST_OVERRIDES", "dog=0.6, dog:siren=0.9")
+
+	cfg, err := state.Read_Configuration(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Record_Inspect_Trust != 0.75 {
+		t.Errorf("Expected trust 0.75, got %.2f", cfg.Record_Inspect_Trust)
+	}
+	if cfg.Trust("dog", "siren") != 0.9 || cfg.Trust("dog", "bark") != 0.6 {
+		t.Errorf("Unexpected overrides: %v", cfg.Record_Trust_Overrides)
+	}
+
+	t.Setenv("RECORD_TRUST_OVERRIDES", "dog")
+	if _, err := state.Read_Configuration(path); err == nil {
+		t.Error("Expected error for invalid overrides")
+	}
+}
+
+// Ensemble models are scanned, but only inspection models report on their own
+func TestRead_Configuration_Ensembles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(config string) {
+		if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"inspect_models": ["a"], "ensembles": [
+		{"name": "dogs", "class": "bark", "rule": "all", "models": ["a", "b"]}]}`)
+	cfg, err := state.Read_Configuration(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if models := cfg.Scanned_Models(); len(models) != 2 || models[1] != "b" {
+		t.Errorf("Expected models [a b], got %v", models)
+	}
+	if !cfg.Reported("a") || cfg.Reported("b") {
+		t.Error("Expected only model a to report detections")
+	}
+
+	write(`{"ensembles": [{"name": "dogs", "class": "bark", "rule": "most", "models": ["a"]}]}`)
+	if _, err := state.Read_Configuration(path); err == nil {
+		t.Error("Expected error for unknown rule")
+	}
+}